@@ -3,12 +3,22 @@ package main
 import (
 	"flag"
 	"github.com/golang/glog"
+	"github.com/starcloud-ai/kubeconfig/pkg/capability"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
 	"github.com/starcloud-ai/kubeconfig/pkg/restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"net/http"
 	"os"
+	"path"
+	"strings"
+	"time"
 )
 
 var (
@@ -20,6 +30,16 @@ var (
 	tillerRole            = "tiller-user"
 	tillerNamespace       = "kube-system"
 	sriovDefaultNamespace = "default"
+	datasetNamespace      = "default"
+	roleCeilingPolicy     = ""
+	helmMode              = rbac.DefaultHelmMode
+	capabilityRefresh     = capability.DefaultRefreshInterval
+	oidcIssuerURL         = ""
+	oidcClientID          = ""
+	networkSelection      *network.Selection
+	allowedStorageClasses []string
+	storageBudget         *resource.Quantity
+	namespacePatchable    []string
 )
 
 func init() {
@@ -65,20 +85,128 @@ func main() {
 	if t := os.Getenv("SRIOV_DEFAULT_NAMESPACE"); t != "" {
 		sriovDefaultNamespace = t
 	}
+	if t := os.Getenv("DATASET_NAMESPACE"); t != "" {
+		datasetNamespace = t
+	}
+	// without an allow list tenants may provision volumes from every storage class
+	// hostpath volumes are refused unless the directories they may be made of are configured
+	if t := os.Getenv("HOSTPATH_ALLOWED_PREFIXES"); t != "" {
+		for _, each := range strings.Split(t, ",") {
+			if !path.IsAbs(each) || path.Clean(each) == "/" {
+				glog.Fatalf("Invalid allowed host path: %s", each)
+			}
+			storage.AllowedHostPaths = append(storage.AllowedHostPaths, path.Clean(each))
+		}
+	}
+	if t := os.Getenv("STORAGECLASS_ALLOWED"); t != "" {
+		allowedStorageClasses = strings.Split(t, ",")
+	}
+	// without a default budget tenants may claim any storage until one is set for them
+	if t := os.Getenv("STORAGE_BUDGET_DEFAULT"); t != "" {
+		budget, err := resource.ParseQuantity(t)
+		if err != nil || budget.Sign() < 0 {
+			glog.Fatalf("Invalid default storage budget: %s", t)
+		}
+		storageBudget = &budget
+	}
+	// without an allow list tenants may not change any label or annotation of their namespaces
+	if t := os.Getenv("NAMESPACE_PATCHABLE_KEYS"); t != "" {
+		namespacePatchable = strings.Split(t, ",")
+	}
+	if t := os.Getenv("ROLE_CEILING_POLICY"); t != "" {
+		roleCeilingPolicy = t
+	}
+	if t := os.Getenv("HELM_MODE"); t != "" {
+		helmMode = t
+	}
+	if !rbac.IsValidHelmMode(helmMode) {
+		glog.Fatalf("Unknown helm mode: %s", helmMode)
+	}
+	if t := os.Getenv("OIDC_ISSUER_URL"); t != "" {
+		oidcIssuerURL = t
+	}
+	if t := os.Getenv("OIDC_CLIENT_ID"); t != "" {
+		oidcClientID = t
+	}
+	// without a default set every network attachment of SRIOV_DEFAULT_NAMESPACE is copied
+	names, selector := os.Getenv("NETWORK_ATTACHMENTS_DEFAULT_NAMES"), os.Getenv("NETWORK_ATTACHMENTS_DEFAULT_SELECTOR")
+	if names != "" || selector != "" {
+		networkSelection = &network.Selection{Selector: selector}
+		if names != "" {
+			networkSelection.Names = strings.Split(names, ",")
+		}
+		if err := networkSelection.Validate(); err != nil {
+			glog.Fatalf("Error parsing network attachments default selector: %s", err.Error())
+		}
+	}
+	if t := os.Getenv("CAPABILITY_REFRESH_INTERVAL"); t != "" {
+		capabilityRefresh, err = time.ParseDuration(t)
+		if err != nil || capabilityRefresh <= 0 {
+			glog.Fatalf("Invalid capability refresh interval: %s", t)
+		}
+	}
+	if t := os.Getenv("READONLY_AGGREGATION_LABEL"); t != "" {
+		rbac.ReadOnlyAggregationLabel = t
+	}
+
+	var ceilingRules []rbacV1.PolicyRule
+	if roleCeilingPolicy != "" {
+		ceilingRules, err = rbac.LoadCeilingRules(roleCeilingPolicy)
+		if err != nil {
+			glog.Fatalf("Error loading role ceiling policy: %s", err.Error())
+		}
+	}
 
 	clientSet, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		glog.Fatalf("Error building kubeclient: %s", err.Error())
 	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		glog.Fatalf("Error building dynamic client: %s", err.Error())
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	// optional integrations are detected now and on every refresh, the steps and rules needing them follow
+	detector := capability.NewDetector(tillerNamespace, capabilityRefresh, clientSet)
+	if err := detector.Refresh(); err != nil {
+		glog.Warningf("Error detecting cluster capabilities: %s", err.Error())
+	}
+	go detector.Run(stopCh)
+	rbac.APIGroupAvailable = func(group string) bool {
+		return group != rbac.KubeflowAPIGroup || detector.Has(capability.TFJobs)
+	}
+	rbac.TillerAvailable = func() bool {
+		return detector.Has(capability.Tiller)
+	}
+
+	// changes of the network attachments in SRIOV_DEFAULT_NAMESPACE are propagated to the tenant copies
+	networks := network.NewManager(sriovDefaultNamespace, networkSelection, dynamicClient)
+	networks.Available = func() bool {
+		return detector.Has(capability.Multus)
+	}
+	go networks.Run(stopCh)
 
 	handler := restful.CreateHandler(clientSet,
+		dynamicClient,
 		namespacePrefix,
 		clusterServer,
 		clusterCAData,
+		oidcIssuerURL,
+		oidcClientID,
+		helmMode,
 		tillerNamespace,
 		tillerRole,
-		sriovDefaultNamespace,
-		swaggerUIDist)
+		swaggerUIDist,
+		ceilingRules,
+		networks,
+		detector,
+		storage.NewDatasets(datasetNamespace, clientSet),
+		storage.NewClasses(allowedStorageClasses, clientSet),
+		storageBudget,
+		namespacePatchable)
 	err = http.ListenAndServe(":8085", handler)
 	if err != nil {
 		glog.Fatalf("Error running http server: %s", err.Error())
@@ -0,0 +1,178 @@
+package capability
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Multus serves the network attachment definitions copied into tenants
+	Multus = "multus"
+	// TFJobs is the kubeflow operator the profile roles and the readonly role grant access to
+	TFJobs = "tfjobs"
+	// Tiller is helm 2, accounts are bound to the tiller role in helm2 mode
+	Tiller = "tiller"
+
+	DefaultRefreshInterval = 5 * time.Minute
+
+	tillerSelector = "app=helm,name=tiller"
+)
+
+// Status tells whether one optional integration has been detected
+type Status struct {
+	Name      string `json:"name" description:"name of the integration"`
+	Available bool   `json:"available" description:"whether the integration has been detected"`
+	Detail    string `json:"detail" description:"what has been looked for"`
+	// GroupVersion is the preferred version serving the resource of the integration, if it has one
+	GroupVersion string `json:"groupVersion,omitempty" description:"api version serving the integration"`
+}
+
+// Detector discovers which optional integrations the cluster runs, and keeps the answer up to date
+type Detector struct {
+	K8sClient       kubernetes.Interface
+	TillerNamespace string
+	RefreshInterval time.Duration
+
+	mutex       sync.RWMutex
+	statuses    map[string]Status
+	refreshedAt time.Time
+	lastError   string
+}
+
+func NewDetector(tillerNamespace string, refreshInterval time.Duration, k8sClient kubernetes.Interface) (detector *Detector) {
+	detector = &Detector{}
+	detector.K8sClient = k8sClient
+	detector.TillerNamespace = tillerNamespace
+	detector.RefreshInterval = refreshInterval
+	detector.statuses = map[string]Status{}
+	return
+}
+
+// Has tells if the integration has been detected. Until one refresh succeeds the answer is unknown
+// and every integration counts as available, as it did before they were detected, so that a failed
+// discovery at startup does not provision accounts without the rules and bindings of an integration
+// or remove them from the shared roles.
+func (detector *Detector) Has(name string) bool {
+	detector.mutex.RLock()
+	defer detector.mutex.RUnlock()
+	status, known := detector.statuses[name]
+	return !known || status.Available
+}
+
+// Statuses returns every integration sorted by name, when they were detected and the last error met
+func (detector *Detector) Statuses() ([]Status, time.Time, string) {
+	detector.mutex.RLock()
+	defer detector.mutex.RUnlock()
+	var list []Status
+	for _, status := range detector.statuses {
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, detector.refreshedAt, detector.lastError
+}
+
+// Refresh detects the integrations again, if it fails the previous answer is kept
+func (detector *Detector) Refresh() error {
+	multus, err := detector.resourceVersion("k8s.cni.cncf.io", "network-attachment-definitions")
+	if err == nil {
+		var tfjobs string
+		var tiller bool
+		tfjobs, err = detector.resourceVersion("kubeflow.org", "tfjobs")
+		if err == nil {
+			tiller, err = detector.hasTiller()
+		}
+		if err == nil {
+			detector.update(map[string]Status{
+				Multus: {Name: Multus, Available: multus != "", Detail: "network-attachment-definitions.k8s.cni.cncf.io",
+					GroupVersion: multus},
+				TFJobs: {Name: TFJobs, Available: tfjobs != "", Detail: "tfjobs.kubeflow.org", GroupVersion: tfjobs},
+				Tiller: {Name: Tiller, Available: tiller,
+					Detail: fmt.Sprintf("deployment %s in namespace %s", tillerSelector, detector.TillerNamespace)},
+			})
+			return nil
+		}
+	}
+
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	detector.lastError = err.Error()
+	return err
+}
+
+// Run refreshes the integrations every RefreshInterval until stopCh is closed
+func (detector *Detector) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(detector.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if err := detector.Refresh(); err != nil {
+				glog.Warningf("refreshing cluster capabilities: %s", err)
+			}
+		}
+	}
+}
+
+func (detector *Detector) update(statuses map[string]Status) {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	for name, status := range statuses {
+		if previous, ok := detector.statuses[name]; !ok || previous.Available != status.Available {
+			glog.Infof("capability %s available: %t", name, status.Available)
+		}
+	}
+	detector.statuses = statuses
+	detector.refreshedAt = time.Now()
+	detector.lastError = ""
+}
+
+// GroupVersion returns the api version serving the integration, empty when it is not available
+func (detector *Detector) GroupVersion(name string) string {
+	detector.mutex.RLock()
+	defer detector.mutex.RUnlock()
+	return detector.statuses[name].GroupVersion
+}
+
+// resourceVersion returns the version of group serving resource, the preferred one first, empty when none does
+func (detector *Detector) resourceVersion(group, resource string) (string, error) {
+	groups, err := detector.K8sClient.Discovery().ServerGroups()
+	if err != nil {
+		return "", err
+	}
+	for _, each := range groups.Groups {
+		if each.Name != group {
+			continue
+		}
+		versions := append([]metaV1.GroupVersionForDiscovery{each.PreferredVersion}, each.Versions...)
+		for _, version := range versions {
+			resources, err := detector.K8sClient.Discovery().ServerResourcesForGroupVersion(version.GroupVersion)
+			if err != nil {
+				return "", err
+			}
+			for _, served := range resources.APIResources {
+				if served.Name == resource {
+					return version.GroupVersion, nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+func (detector *Detector) hasTiller() (bool, error) {
+	deployments, err := detector.K8sClient.AppsV1().Deployments(detector.TillerNamespace).List(metaV1.ListOptions{
+		LabelSelector: tillerSelector,
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(deployments.Items) > 0, nil
+}
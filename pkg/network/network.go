@@ -0,0 +1,258 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// SourceLabel marks the definitions copied by the service, its value is the name of the source definition
+	SourceLabel = "clustar.ai/network-source"
+)
+
+// 使用curl通过api访问集群
+// kubectl proxy --port=8080 &
+// 获取某个namespace下的资源
+// http://localhost:8080/apis/k8s.cni.cncf.io/v1/namespaces/default/network-attachment-definitions
+var definitionsResource = schema.GroupVersionResource{
+	Group:    "k8s.cni.cncf.io",
+	Version:  "v1",
+	Resource: "network-attachment-definitions",
+}
+
+// Selection picks network attachment definitions of the source namespace,
+// a definition is selected when its name is listed or its labels match the selector, an empty selection picks nothing
+type Selection struct {
+	Names    []string `json:"names,omitempty" description:"names of the network attachment definitions in the source namespace"`
+	Selector string   `json:"selector,omitempty" description:"label selector of the network attachment definitions in the source namespace"`
+}
+
+func (selection *Selection) Validate() error {
+	_, err := labels.Parse(selection.Selector)
+	return err
+}
+
+// Manager copies network attachment definitions from the source namespace into tenant namespaces.
+// The definitions are handled as unstructured objects, so copies keep everything of their source.
+type Manager struct {
+	Dynamic         dynamic.Interface
+	SourceNamespace string
+	// DefaultSelection is used when a tenant does not choose, nil selects every definition
+	DefaultSelection *Selection
+	// Available tells if multus is installed, nil means it always is
+	Available func() bool
+}
+
+func NewManager(sourceNamespace string, defaultSelection *Selection, dynamicClient dynamic.Interface) (manager *Manager) {
+	manager = &Manager{}
+	manager.Dynamic = dynamicClient
+	manager.SourceNamespace = sourceNamespace
+	manager.DefaultSelection = defaultSelection
+	return
+}
+
+// IsAvailable tells if the cluster serves network attachment definitions at all
+func (manager *Manager) IsAvailable() bool {
+	return manager.Available == nil || manager.Available()
+}
+
+// Select returns the definitions of the source namespace picked by selection, or by the default one when it is nil.
+// Listed names which do not exist in the source namespace are an error.
+func (manager *Manager) Select(selection *Selection) ([]unstructured.Unstructured, error) {
+	if selection == nil {
+		selection = manager.DefaultSelection
+	}
+	if selection == nil {
+		return manager.list(manager.SourceNamespace, "")
+	}
+	if err := selection.Validate(); err != nil {
+		return nil, err
+	}
+
+	all, err := manager.list(manager.SourceNamespace, "")
+	if err != nil {
+		return nil, err
+	}
+	selector, _ := labels.Parse(selection.Selector)
+	byName := map[string]bool{}
+	for _, name := range selection.Names {
+		byName[name] = true
+	}
+
+	var selected []unstructured.Unstructured
+	for _, each := range all {
+		if byName[each.GetName()] {
+			delete(byName, each.GetName())
+			selected = append(selected, each)
+		} else if selection.Selector != "" && selector.Matches(labels.Set(each.GetLabels())) {
+			selected = append(selected, each)
+		}
+	}
+	if len(byName) > 0 {
+		var missing []string
+		for name := range byName {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return nil, &UnknownDefinitionsError{Namespace: manager.SourceNamespace, Names: missing}
+	}
+	return selected, nil
+}
+
+// Copy makes sure namespace has an up to date copy of every definition, it may be called again for the
+// same namespace. Copies customised by the tenant and definitions the service did not create are left alone,
+// unlabeled definitions with the configuration of their source are adopted as copies made before the label.
+func (manager *Manager) Copy(namespace string, definitions []unstructured.Unstructured) ([]SyncResult, error) {
+	var results []SyncResult
+	for i := range definitions {
+		source := &definitions[i]
+		var result SyncResult
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current, err := manager.get(namespace, source.GetName())
+			if k8sError.IsNotFound(err) {
+				result = SyncResult{Namespace: namespace, Name: source.GetName(), Action: SyncCreated}
+				_, err = manager.Dynamic.Resource(definitionsResource).Namespace(namespace).
+					Create(newCopy(source, namespace), metaV1.CreateOptions{})
+				if k8sError.IsAlreadyExists(err) {
+					// created meanwhile, the next attempt updates it instead
+					return k8sError.NewConflict(definitionsResource.GroupResource(), source.GetName(), err)
+				}
+				return err
+			}
+			if err != nil {
+				return err
+			}
+			if _, ok := current.GetLabels()[SourceLabel]; !ok {
+				result, err = manager.adoptCopy(current, source)
+				return err
+			}
+			result, err = manager.syncCopy(current, source, false)
+			return err
+		})
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ListManaged returns the definitions inside namespace which have been copied by the service
+func (manager *Manager) ListManaged(namespace string) ([]unstructured.Unstructured, error) {
+	return manager.list(namespace, SourceLabel)
+}
+
+// listCopies returns the copies of the source definition name in every namespace
+func (manager *Manager) listCopies(name string) ([]unstructured.Unstructured, error) {
+	copies, err := manager.list(metaV1.NamespaceAll, fmt.Sprintf("%s=%s", SourceLabel, name))
+	if err != nil {
+		return nil, err
+	}
+	var list []unstructured.Unstructured
+	for _, each := range copies {
+		if each.GetNamespace() != manager.SourceNamespace {
+			list = append(list, each)
+		}
+	}
+	return list, nil
+}
+
+// Remove deletes a definition copied by the service, definitions created by the tenant are refused
+func (manager *Manager) Remove(namespace, name string) error {
+	definition, err := manager.get(namespace, name)
+	if err != nil {
+		return err
+	}
+	if _, ok := definition.GetLabels()[SourceLabel]; !ok {
+		return &NotManagedError{Namespace: namespace, Name: name}
+	}
+	return manager.delete(namespace, name)
+}
+
+func (manager *Manager) get(namespace, name string) (*unstructured.Unstructured, error) {
+	return manager.Dynamic.Resource(definitionsResource).Namespace(namespace).Get(name, metaV1.GetOptions{})
+}
+
+func (manager *Manager) update(definition *unstructured.Unstructured) error {
+	_, err := manager.Dynamic.Resource(definitionsResource).Namespace(definition.GetNamespace()).
+		Update(definition, metaV1.UpdateOptions{})
+	return err
+}
+
+func (manager *Manager) delete(namespace, name string) error {
+	return manager.Dynamic.Resource(definitionsResource).Namespace(namespace).Delete(name, &metaV1.DeleteOptions{})
+}
+
+// list returns the definitions inside namespace, or inside all namespaces for metaV1.NamespaceAll
+func (manager *Manager) list(namespace, labelSelector string) ([]unstructured.Unstructured, error) {
+	options := metaV1.ListOptions{LabelSelector: labelSelector}
+	var list *unstructured.UnstructuredList
+	var err error
+	if namespace == metaV1.NamespaceAll {
+		list, err = manager.Dynamic.Resource(definitionsResource).List(options)
+	} else {
+		list, err = manager.Dynamic.Resource(definitionsResource).Namespace(namespace).List(options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		if list.Items[i].GetNamespace() != list.Items[j].GetNamespace() {
+			return list.Items[i].GetNamespace() < list.Items[j].GetNamespace()
+		}
+		return list.Items[i].GetName() < list.Items[j].GetName()
+	})
+	return list.Items, nil
+}
+
+// newCopy returns source placed into namespace, without the fields the api server sets on an object
+func newCopy(source *unstructured.Unstructured, namespace string) *unstructured.Unstructured {
+	definition := source.DeepCopy()
+	definition.SetNamespace(namespace)
+	definition.SetUID("")
+	definition.SetResourceVersion("")
+	definition.SetSelfLink("")
+	definition.SetGeneration(0)
+	definition.SetCreationTimestamp(metaV1.Time{})
+	definition.SetOwnerReferences(nil)
+	definition.SetFinalizers(nil)
+	unstructured.RemoveNestedField(definition.Object, "status")
+
+	markCopy(definition, source)
+	return definition
+}
+
+// Config returns the CNI configuration of a definition
+func Config(definition unstructured.Unstructured) string {
+	config, _, _ := unstructured.NestedString(definition.Object, "spec", "config")
+	return config
+}
+
+// NotManagedError is returned when a definition was not copied by the service
+type NotManagedError struct {
+	Namespace string
+	Name      string
+}
+
+func (e *NotManagedError) Error() string {
+	return fmt.Sprintf("network attachment definition %s/%s is not managed by the service", e.Namespace, e.Name)
+}
+
+// UnknownDefinitionsError lists the selected names which do not exist in the source namespace
+type UnknownDefinitionsError struct {
+	Namespace string
+	Names     []string
+}
+
+func (e *UnknownDefinitionsError) Error() string {
+	return fmt.Sprintf("network attachment definitions %s do not exist in namespace %s", strings.Join(e.Names, ", "), e.Namespace)
+}
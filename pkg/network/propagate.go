@@ -0,0 +1,311 @@
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"time"
+
+	"github.com/golang/glog"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// SpecHashAnnotation records the hash of the source spec a copy was made from,
+	// a copy whose spec no longer matches it has been customised by the tenant
+	SpecHashAnnotation = "clustar.ai/network-spec-hash"
+
+	SyncCreated   = "created"
+	SyncUpdated   = "updated"
+	SyncDeleted   = "deleted"
+	SyncUnchanged = "unchanged"
+	SyncSkipped   = "skipped"
+	SyncAdopted   = "adopted"
+
+	watchRetryInterval = 10 * time.Second
+)
+
+// SyncResult tells what happened to one copy when its source was propagated
+type SyncResult struct {
+	Namespace string `json:"namespace" description:"namespace of the copy"`
+	Name      string `json:"name" description:"name of the copy"`
+	Action    string `json:"action" description:"created, updated, deleted, unchanged, skipped or adopted"`
+	Reason    string `json:"reason,omitempty" description:"why the copy was skipped"`
+}
+
+// specHash covers the CNI configuration, the only field of the spec
+func specHash(definition *unstructured.Unstructured) string {
+	sum := sha256.Sum256([]byte(Config(*definition)))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsCustomised tells if a copy has been changed since the service made it,
+// copies without a recorded hash cannot be told apart from customised ones
+func IsCustomised(definition unstructured.Unstructured) bool {
+	hash, ok := definition.GetAnnotations()[SpecHashAnnotation]
+	return !ok || hash != specHash(&definition)
+}
+
+// markCopy records the source of the copy and the hash of the spec it has been given
+func markCopy(definition, source *unstructured.Unstructured) {
+	copyLabels := definition.GetLabels()
+	if copyLabels == nil {
+		copyLabels = map[string]string{}
+	}
+	copyLabels[SourceLabel] = source.GetName()
+	definition.SetLabels(copyLabels)
+
+	annotations := definition.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SpecHashAnnotation] = specHash(source)
+	definition.SetAnnotations(annotations)
+}
+
+// Propagate brings every copy of the source definition name in line with it, or removes the copies
+// when the source is gone. Customised copies are skipped unless force is set.
+func (manager *Manager) Propagate(name string, force bool) ([]SyncResult, error) {
+	source, err := manager.get(manager.SourceNamespace, name)
+	if k8sError.IsNotFound(err) {
+		source, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	copies, err := manager.listCopies(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SyncResult
+	for i := range copies {
+		copy := &copies[i]
+		var result SyncResult
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			var err error
+			result, err = manager.syncCopy(copy, source, force)
+			if k8sError.IsConflict(err) {
+				// changed meanwhile, the next attempt looks at the current copy
+				if current, getErr := manager.get(copy.GetNamespace(), copy.GetName()); getErr == nil {
+					copy = current
+				}
+			}
+			return err
+		})
+		if err != nil && !k8sError.IsNotFound(err) {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Sync brings the copy name inside namespace back in line with its source
+func (manager *Manager) Sync(namespace, name string, force bool) (SyncResult, error) {
+	var result SyncResult
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		definition, err := manager.get(namespace, name)
+		if err != nil {
+			return err
+		}
+		sourceName, ok := definition.GetLabels()[SourceLabel]
+		if !ok {
+			return &NotManagedError{Namespace: namespace, Name: name}
+		}
+		source, err := manager.get(manager.SourceNamespace, sourceName)
+		if k8sError.IsNotFound(err) {
+			source, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+		result, err = manager.syncCopy(definition, source, force)
+		return err
+	})
+	return result, err
+}
+
+// Adopt marks the definitions inside namespace which were copied before copies were labeled,
+// so that they are propagated to from then on. A definition is only taken for a copy when a source
+// of the same name exists and has the same CNI configuration, the others are left to the tenant.
+func (manager *Manager) Adopt(namespace string) ([]SyncResult, error) {
+	if namespace == manager.SourceNamespace {
+		return nil, nil
+	}
+	definitions, err := manager.list(namespace, "")
+	if err != nil {
+		return nil, err
+	}
+	var results []SyncResult
+	for i := range definitions {
+		definition := &definitions[i]
+		if _, ok := definition.GetLabels()[SourceLabel]; ok {
+			continue
+		}
+		source, err := manager.get(manager.SourceNamespace, definition.GetName())
+		if k8sError.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return results, err
+		}
+		result, err := manager.adoptCopy(definition, source)
+		if err != nil {
+			return results, err
+		}
+		if result.Action == SyncAdopted {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// adoptCopy labels definition as a copy of source when both have the same CNI configuration
+func (manager *Manager) adoptCopy(definition, source *unstructured.Unstructured) (SyncResult, error) {
+	result := SyncResult{Namespace: definition.GetNamespace(), Name: definition.GetName()}
+	if Config(*definition) != Config(*source) {
+		result.Action = SyncSkipped
+		result.Reason = "not created by the service"
+		return result, nil
+	}
+	adopted := definition.DeepCopy()
+	markCopy(adopted, source)
+	result.Action = SyncAdopted
+	return result, manager.update(adopted)
+}
+
+// syncCopy updates the copy to the spec, labels and annotations of source, or deletes it when source is nil.
+// Labels and annotations the tenant added to the copy are kept.
+func (manager *Manager) syncCopy(copy, source *unstructured.Unstructured, force bool) (SyncResult, error) {
+	result := SyncResult{Namespace: copy.GetNamespace(), Name: copy.GetName()}
+	if !force && IsCustomised(*copy) {
+		result.Action = SyncSkipped
+		result.Reason = "customised by the tenant"
+		return result, nil
+	}
+
+	if source == nil {
+		result.Action = SyncDeleted
+		return result, ignoreNotFound(manager.delete(copy.GetNamespace(), copy.GetName()))
+	}
+
+	desired := copy.DeepCopy()
+	desired.Object["spec"] = source.DeepCopy().Object["spec"]
+	desired.SetLabels(mergeStrings(copy.GetLabels(), source.GetLabels()))
+	desired.SetAnnotations(mergeStrings(copy.GetAnnotations(), source.GetAnnotations()))
+	markCopy(desired, source)
+	if reflect.DeepEqual(desired.Object, copy.Object) {
+		result.Action = SyncUnchanged
+		return result, nil
+	}
+	result.Action = SyncUpdated
+	return result, manager.update(desired)
+}
+
+func mergeStrings(current, wanted map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range wanted {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Run watches the source namespace and propagates every change to the copies until stopCh is closed.
+// Each time the watch is established all sources are propagated, so changes missed meanwhile are caught up.
+func (manager *Manager) Run(stopCh <-chan struct{}) {
+	for {
+		var err error
+		if manager.IsAvailable() {
+			err = manager.watch(stopCh)
+		}
+		if err != nil {
+			glog.Warningf("watching network attachment definitions of %s: %s", manager.SourceNamespace, err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+func (manager *Manager) watch(stopCh <-chan struct{}) error {
+	sources, err := manager.Dynamic.Resource(definitionsResource).Namespace(manager.SourceNamespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	// copies of sources deleted meanwhile are only found through their label
+	names := map[string]bool{}
+	for _, each := range sources.Items {
+		names[each.GetName()] = true
+	}
+	copies, err := manager.list(metaV1.NamespaceAll, SourceLabel)
+	if err != nil {
+		return err
+	}
+	for _, each := range copies {
+		names[each.GetLabels()[SourceLabel]] = true
+	}
+	for name := range names {
+		manager.logPropagation(name)
+	}
+
+	watcher, err := manager.Dynamic.Resource(definitionsResource).Namespace(manager.SourceNamespace).Watch(metaV1.ListOptions{
+		ResourceVersion: sources.GetResourceVersion(),
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return nil
+			}
+			switch event.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+				if object, ok := event.Object.(*unstructured.Unstructured); ok {
+					manager.logPropagation(object.GetName())
+				}
+			case watch.Error:
+				return k8sError.FromObject(event.Object)
+			}
+		}
+	}
+}
+
+func (manager *Manager) logPropagation(name string) {
+	results, err := manager.Propagate(name, false)
+	for _, result := range results {
+		switch result.Action {
+		case SyncUpdated, SyncDeleted:
+			glog.Infof("network attachment definition %s/%s %s from %s/%s",
+				result.Namespace, result.Name, result.Action, manager.SourceNamespace, name)
+		case SyncSkipped:
+			glog.Warningf("network attachment definition %s/%s skipped: %s", result.Namespace, result.Name, result.Reason)
+		}
+	}
+	if err != nil {
+		glog.Errorf("propagating network attachment definition %s/%s: %s", manager.SourceNamespace, name, err)
+	}
+}
+
+func ignoreNotFound(err error) error {
+	if k8sError.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+package network
+
+import (
+	"testing"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+)
+
+func newDefinition(namespace, name, config string) *unstructured.Unstructured {
+	definition := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "k8s.cni.cncf.io/v1",
+		"kind":       "NetworkAttachmentDefinition",
+		"spec":       map[string]interface{}{"config": config},
+	}}
+	definition.SetNamespace(namespace)
+	definition.SetName(name)
+	return definition
+}
+
+// newTestManager returns a manager on a fake cluster holding definitions, the source namespace is sriov
+func newTestManager(t *testing.T, definitions ...*unstructured.Unstructured) *Manager {
+	manager := NewManager("sriov", nil, dynamicFake.NewSimpleDynamicClient(runtime.NewScheme()))
+	for _, each := range definitions {
+		_, err := manager.Dynamic.Resource(definitionsResource).Namespace(each.GetNamespace()).
+			Create(each, metaV1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return manager
+}
+
+func TestSyncCopy(t *testing.T) {
+	source := newDefinition("sriov", "net1", `{"type": "sriov", "vlan": 2}`)
+	customised := newCopy(newDefinition("sriov", "net1", `{"type": "sriov", "vlan": 1}`), "clustar-a")
+	unstructured.SetNestedField(customised.Object, `{"type": "sriov", "vlan": 9}`, "spec", "config")
+	tests := []struct {
+		name   string
+		copy   *unstructured.Unstructured
+		source *unstructured.Unstructured
+		force  bool
+		action string
+		config string
+	}{
+		{name: "outdated copy", copy: newCopy(newDefinition("sriov", "net1", `{"type": "sriov", "vlan": 1}`), "clustar-a"),
+			source: source, action: SyncUpdated, config: Config(*source)},
+		{name: "current copy", copy: newCopy(source, "clustar-a"), source: source, action: SyncUnchanged, config: Config(*source)},
+		{name: "customised copy", copy: customised, source: source, action: SyncSkipped, config: `{"type": "sriov", "vlan": 9}`},
+		{name: "customised copy forced", copy: customised, source: source, force: true, action: SyncUpdated, config: Config(*source)},
+		{name: "deleted source", copy: newCopy(source, "clustar-a"), action: SyncDeleted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := newTestManager(t, test.copy)
+			result, err := manager.syncCopy(test.copy, test.source, test.force)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Action != test.action {
+				t.Errorf("action = %s, want %s", result.Action, test.action)
+			}
+			current, err := manager.get("clustar-a", "net1")
+			if test.action == SyncDeleted {
+				if err == nil {
+					t.Error("the copy of the deleted source is left")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if Config(*current) != test.config {
+				t.Errorf("config = %s, want %s", Config(*current), test.config)
+			}
+		})
+	}
+}
+
+func TestPropagateDeletedSource(t *testing.T) {
+	source := newDefinition("sriov", "net1", `{"type": "sriov"}`)
+	manager := newTestManager(t, newCopy(source, "clustar-a"), newCopy(source, "clustar-b"))
+	results, err := manager.Propagate("net1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results = %+v, want both copies deleted", results)
+	}
+	for _, result := range results {
+		if result.Action != SyncDeleted {
+			t.Errorf("copy %s/%s %s, want deleted", result.Namespace, result.Name, result.Action)
+		}
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	manager := newTestManager(t,
+		newDefinition("sriov", "net1", `{"type": "sriov"}`),
+		newDefinition("sriov", "net2", `{"type": "sriov", "vlan": 2}`),
+		// copied before copies were labeled
+		newDefinition("clustar-a", "net1", `{"type": "sriov"}`),
+		// same name, but another configuration
+		newDefinition("clustar-a", "net2", `{"type": "macvlan"}`),
+		// created by the tenant
+		newDefinition("clustar-a", "own", `{"type": "bridge"}`),
+	)
+	results, err := manager.Adopt("clustar-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "net1" || results[0].Action != SyncAdopted {
+		t.Fatalf("results = %+v, want net1 adopted", results)
+	}
+	copies, err := manager.listCopies("net1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copies) != 1 || IsCustomised(copies[0]) {
+		t.Errorf("copies of net1 = %+v, want the adopted one, not customised", copies)
+	}
+	for _, name := range []string{"net2", "own"} {
+		definition, err := manager.get("clustar-a", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := definition.GetLabels()[SourceLabel]; ok {
+			t.Errorf("%s adopted although it is not a copy", name)
+		}
+	}
+}
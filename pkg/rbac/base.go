@@ -1,6 +1,15 @@
 package rbac
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
 
 const roleBindingPattern = "%s:%s:%s-binding"
 
@@ -10,10 +19,282 @@ type BaseRole struct {
 }
 
 type RbacInterface interface {
+	GetRoleName() string
 	CreateRole() error
-	CreateRoleBinding(accountNamespace, accountName string) error
+	// the bindings of an account refer to subjects, or only to its ServiceAccount when subjects is empty
+	CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error
+	// ReconcileRole and ReconcileRoleBinding bring existing objects back to their template,
+	// they create missing objects as well and tell if anything has been changed
+	ReconcileRole() (bool, error)
+	ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error)
+	// DeleteRoleBinding does not fail if the binding is already gone. Roles are never deleted through
+	// the interface, the shared ones are bound by every tenant and the others are not managed by the service.
+	DeleteRoleBinding(accountNamespace, accountName string) error
+	RoleExists() (bool, error)
+	RoleBindingExists(accountNamespace, accountName string) (bool, error)
+	Describe() RoleDescription
+}
+
+// RoleDescription tells where a role and its bindings live
+type RoleDescription struct {
+	RoleKind         string
+	RoleNamespace    string
+	RoleName         string
+	BindingKind      string
+	BindingNamespace string
+	// Managed roles are created and reconciled by the service, the others are only bound
+	Managed bool
+}
+
+func (role *BaseRole) GetRoleName() string {
+	return role.RoleName
 }
 
 func GenerateRoleBindingName(role, accountNamespace, accountName string) string {
 	return fmt.Sprintf(roleBindingPattern, accountNamespace, accountName, role)
 }
+
+// every binding created for an account starts with "{namespace}:{account}:",
+// neither part may contain a colon, so the prefix identifies the account
+func accountBindingPrefix(accountNamespace, accountName string) string {
+	return fmt.Sprintf("%s:%s:", accountNamespace, accountName)
+}
+
+// ParseRoleBindingName returns the account a binding created by GenerateRoleBindingName belongs to
+func ParseRoleBindingName(bindingName string) (accountNamespace, accountName string, ok bool) {
+	parts := strings.SplitN(bindingName, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || !strings.HasSuffix(parts[2], "-binding") {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func isAccountBinding(bindingName, accountNamespace, accountName string) bool {
+	return strings.HasPrefix(bindingName, accountBindingPrefix(accountNamespace, accountName))
+}
+
+func createRoleIfNotExists(k8sClient kubernetes.Interface, roleTmp *rbacV1.Role) error {
+	_, err := k8sClient.RbacV1().Roles(roleTmp.Namespace).Get(roleTmp.Name, metaV1.GetOptions{})
+	if err != nil {
+		switch t := err.(type) {
+		case *k8sError.StatusError:
+			if t.Status().Reason == metaV1.StatusReasonNotFound {
+				_, err = k8sClient.RbacV1().Roles(roleTmp.Namespace).Create(roleTmp)
+				return err
+			}
+			return err
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func createRoleBindingIfNotExists(k8sClient kubernetes.Interface, bindingTmp *rbacV1.RoleBinding) error {
+	_, err := k8sClient.RbacV1().RoleBindings(bindingTmp.Namespace).Get(bindingTmp.Name, metaV1.GetOptions{})
+	if err != nil {
+		switch t := err.(type) {
+		case *k8sError.StatusError:
+			if t.Status().Reason == metaV1.StatusReasonNotFound {
+				_, err = k8sClient.RbacV1().RoleBindings(bindingTmp.Namespace).Create(bindingTmp)
+				return err
+			}
+			return err
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func newAccountRoleBinding(namespace, roleKind, roleName, accountNamespace, accountName string, subjects []rbacV1.Subject) *rbacV1.RoleBinding {
+	rolebindingtmp := &rbacV1.RoleBinding{}
+	rolebindingtmp.APIVersion = "v1"
+	rolebindingtmp.Kind = "RoleBinding"
+	rolebindingtmp.Name = GenerateRoleBindingName(roleName, accountNamespace, accountName)
+	rolebindingtmp.Namespace = namespace
+	rolebindingtmp.Subjects = bindingSubjects(accountNamespace, accountName, subjects)
+	rolebindingtmp.RoleRef.Kind = roleKind
+	rolebindingtmp.RoleRef.Name = roleName
+	return rolebindingtmp
+}
+
+func bindingSubjects(accountNamespace, accountName string, subjects []rbacV1.Subject) []rbacV1.Subject {
+	if len(subjects) == 0 {
+		return []rbacV1.Subject{ServiceAccountSubject(accountNamespace, accountName)}
+	}
+	return subjects
+}
+
+func reconcileRole(k8sClient kubernetes.Interface, desired *rbacV1.Role) (bool, error) {
+	current, err := k8sClient.RbacV1().Roles(desired.Namespace).Get(desired.Name, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		_, err = k8sClient.RbacV1().Roles(desired.Namespace).Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if RulesEqual(current.Rules, desired.Rules) {
+		return false, nil
+	}
+	current.Rules = desired.Rules
+	_, err = k8sClient.RbacV1().Roles(desired.Namespace).Update(current)
+	return err == nil, err
+}
+
+func reconcileClusterRole(k8sClient kubernetes.Interface, desired *rbacV1.ClusterRole) (bool, error) {
+	current, err := k8sClient.RbacV1().ClusterRoles().Get(desired.Name, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		_, err = k8sClient.RbacV1().ClusterRoles().Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	// rules of an aggregated role belong to the controller manager, only its selectors are compared
+	if desired.AggregationRule != nil {
+		if current.AggregationRule != nil &&
+			reflect.DeepEqual(current.AggregationRule.ClusterRoleSelectors, desired.AggregationRule.ClusterRoleSelectors) {
+			return false, nil
+		}
+		current.AggregationRule = desired.AggregationRule
+	} else {
+		if current.AggregationRule == nil && RulesEqual(current.Rules, desired.Rules) && labelsContain(current.Labels, desired.Labels) {
+			return false, nil
+		}
+		current.AggregationRule = nil
+		current.Rules = desired.Rules
+		for key, value := range desired.Labels {
+			if current.Labels == nil {
+				current.Labels = map[string]string{}
+			}
+			current.Labels[key] = value
+		}
+	}
+	_, err = k8sClient.RbacV1().ClusterRoles().Update(current)
+	return err == nil, err
+}
+
+func labelsContain(labels, wanted map[string]string) bool {
+	for key, value := range wanted {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// roleRef of a binding is immutable, so a binding which refers to the wrong role is recreated
+func reconcileRoleBinding(k8sClient kubernetes.Interface, desired *rbacV1.RoleBinding) (bool, error) {
+	current, err := k8sClient.RbacV1().RoleBindings(desired.Namespace).Get(desired.Name, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		_, err = k8sClient.RbacV1().RoleBindings(desired.Namespace).Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if !roleRefEqual(current.RoleRef, desired.RoleRef) {
+		err = k8sClient.RbacV1().RoleBindings(desired.Namespace).Delete(desired.Name, &metaV1.DeleteOptions{})
+		if err != nil {
+			return false, err
+		}
+		_, err = k8sClient.RbacV1().RoleBindings(desired.Namespace).Create(desired)
+		return err == nil, err
+	}
+	if subjectsEqual(current.Subjects, desired.Subjects) {
+		return false, nil
+	}
+	current.Subjects = desired.Subjects
+	_, err = k8sClient.RbacV1().RoleBindings(desired.Namespace).Update(current)
+	return err == nil, err
+}
+
+func reconcileClusterRoleBinding(k8sClient kubernetes.Interface, desired *rbacV1.ClusterRoleBinding) (bool, error) {
+	current, err := k8sClient.RbacV1().ClusterRoleBindings().Get(desired.Name, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		_, err = k8sClient.RbacV1().ClusterRoleBindings().Create(desired)
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if !roleRefEqual(current.RoleRef, desired.RoleRef) {
+		err = k8sClient.RbacV1().ClusterRoleBindings().Delete(desired.Name, &metaV1.DeleteOptions{})
+		if err != nil {
+			return false, err
+		}
+		_, err = k8sClient.RbacV1().ClusterRoleBindings().Create(desired)
+		return err == nil, err
+	}
+	if subjectsEqual(current.Subjects, desired.Subjects) {
+		return false, nil
+	}
+	current.Subjects = desired.Subjects
+	_, err = k8sClient.RbacV1().ClusterRoleBindings().Update(current)
+	return err == nil, err
+}
+
+// the object helpers below ignore NotFound when deleting, and turn NotFound into false when checking existence
+
+func deleteClusterRole(k8sClient kubernetes.Interface, name string) error {
+	return ignoreNotFound(k8sClient.RbacV1().ClusterRoles().Delete(name, &metaV1.DeleteOptions{}))
+}
+
+func deleteRoleBinding(k8sClient kubernetes.Interface, namespace, name string) error {
+	return ignoreNotFound(k8sClient.RbacV1().RoleBindings(namespace).Delete(name, &metaV1.DeleteOptions{}))
+}
+
+func deleteClusterRoleBinding(k8sClient kubernetes.Interface, name string) error {
+	return ignoreNotFound(k8sClient.RbacV1().ClusterRoleBindings().Delete(name, &metaV1.DeleteOptions{}))
+}
+
+func roleExists(k8sClient kubernetes.Interface, namespace, name string) (bool, error) {
+	_, err := k8sClient.RbacV1().Roles(namespace).Get(name, metaV1.GetOptions{})
+	return existence(err)
+}
+
+func clusterRoleExists(k8sClient kubernetes.Interface, name string) (bool, error) {
+	_, err := k8sClient.RbacV1().ClusterRoles().Get(name, metaV1.GetOptions{})
+	return existence(err)
+}
+
+func roleBindingExists(k8sClient kubernetes.Interface, namespace, name string) (bool, error) {
+	_, err := k8sClient.RbacV1().RoleBindings(namespace).Get(name, metaV1.GetOptions{})
+	return existence(err)
+}
+
+func clusterRoleBindingExists(k8sClient kubernetes.Interface, name string) (bool, error) {
+	_, err := k8sClient.RbacV1().ClusterRoleBindings().Get(name, metaV1.GetOptions{})
+	return existence(err)
+}
+
+func ignoreNotFound(err error) error {
+	if k8sError.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func existence(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if k8sError.IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// namespacedRoleDescription describes a Role bound by RoleBindings in its own namespace
+func namespacedRoleDescription(namespace, roleName string, managed bool) RoleDescription {
+	return RoleDescription{
+		RoleKind:         "Role",
+		RoleNamespace:    namespace,
+		RoleName:         roleName,
+		BindingKind:      "RoleBinding",
+		BindingNamespace: namespace,
+		Managed:          managed,
+	}
+}
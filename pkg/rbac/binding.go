@@ -0,0 +1,23 @@
+package rbac
+
+import (
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ListAccountRoleBindings returns the RoleBindings of the account inside namespace, an empty namespace means all namespaces
+func ListAccountRoleBindings(k8sClient kubernetes.Interface, accountNamespace, accountName string,
+	namespace string) ([]rbacV1.RoleBinding, error) {
+	bindings, err := k8sClient.RbacV1().RoleBindings(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var result []rbacV1.RoleBinding
+	for _, each := range bindings.Items {
+		if isAccountBinding(each.Name, accountNamespace, accountName) {
+			result = append(result, each)
+		}
+	}
+	return result, nil
+}
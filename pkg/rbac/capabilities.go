@@ -0,0 +1,33 @@
+package rbac
+
+// KubeflowAPIGroup is only served when kubeflow is installed
+const KubeflowAPIGroup = "kubeflow.org"
+
+// APIGroupAvailable tells if an optional api group is served by the cluster, the profile roles and the
+// readonly pieces leave the unavailable groups out. Every group counts as available unless it is replaced.
+var APIGroupAvailable = func(group string) bool { return true }
+
+// TillerAvailable tells if tiller is installed, accounts are only bound to the tiller role when it is
+var TillerAvailable = func() bool { return true }
+
+func availableAPIGroups(groups ...string) []string {
+	var available []string
+	for _, group := range groups {
+		if APIGroupAvailable(group) {
+			available = append(available, group)
+		}
+	}
+	return available
+}
+
+// availableReadonlyPieces splits the default pieces into the ones whose api groups are all served and the others
+func availableReadonlyPieces() (available, unavailable []ReadonlyPiece) {
+	for _, piece := range DefaultReadonlyPieces {
+		if len(availableAPIGroups(piece.APIGroups...)) == len(piece.APIGroups) {
+			available = append(available, piece)
+		} else {
+			unavailable = append(unavailable, piece)
+		}
+	}
+	return
+}
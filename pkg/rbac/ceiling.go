@@ -0,0 +1,140 @@
+package rbac
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultCeilingRules lets tenants grant what the namespace admin role has inside their own namespace,
+// which keeps quotas and limit ranges out of their hands
+var DefaultCeilingRules = adminRules(adminAPIGroups)
+
+// EscalationError lists the permissions which are beyond the ceiling
+type EscalationError struct {
+	Permissions []string
+	Reason      string
+}
+
+func (e *EscalationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, strings.Join(e.Permissions, ", "))
+}
+
+// Ceiling decides which rules tenants may put into roles or bind to, inside their namespaces
+type Ceiling struct {
+	Rules     []rbacV1.PolicyRule
+	K8sClient kubernetes.Interface
+}
+
+func NewCeiling(rules []rbacV1.PolicyRule, k8sclient kubernetes.Interface) (ceiling *Ceiling) {
+	ceiling = &Ceiling{}
+	ceiling.Rules = rules
+	if len(ceiling.Rules) == 0 {
+		ceiling.Rules = DefaultCeilingRules
+	}
+	ceiling.K8sClient = k8sclient
+	return
+}
+
+// LoadCeilingRules reads a list of PolicyRules from a yaml or json file
+func LoadCeilingRules(path string) ([]rbacV1.PolicyRule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []rbacV1.PolicyRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// CheckRole returns an *EscalationError if rules of a Role in namespace go beyond the ceiling,
+// or beyond what the service itself could grant
+func (ceiling *Ceiling) CheckRole(namespace string, rules []rbacV1.PolicyRule) error {
+	return ceiling.check(namespace, rules, "roles", "escalate")
+}
+
+// CheckBinding does the same for the rules of a role which is going to be bound inside namespace
+func (ceiling *Ceiling) CheckBinding(namespace string, rules []rbacV1.PolicyRule) error {
+	// non resource urls mean nothing inside a namespace
+	var resourceRules []rbacV1.PolicyRule
+	for _, rule := range rules {
+		if len(rule.NonResourceURLs) == 0 {
+			resourceRules = append(resourceRules, rule)
+		}
+	}
+	return ceiling.check(namespace, resourceRules, "rolebindings", "bind")
+}
+
+// CheckRoleRef does the same for an existing Role of namespace or ClusterRole, given by kind and name
+func (ceiling *Ceiling) CheckRoleRef(namespace, kind, name string) error {
+	var rules []rbacV1.PolicyRule
+	switch kind {
+	case "Role":
+		role, err := ceiling.K8sClient.RbacV1().Roles(namespace).Get(name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		rules = role.Rules
+	case "ClusterRole":
+		role, err := ceiling.K8sClient.RbacV1().ClusterRoles().Get(name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		rules = role.Rules
+	default:
+		return fmt.Errorf("roleKind: %s must be Role or ClusterRole", kind)
+	}
+	return ceiling.CheckBinding(namespace, rules)
+}
+
+func (ceiling *Ceiling) check(namespace string, rules []rbacV1.PolicyRule, resource, verb string) error {
+	if uncovered := UncoveredPermissions(ceiling.Rules, rules); len(uncovered) > 0 {
+		return &EscalationError{Permissions: uncovered, Reason: "rules exceed the ceiling policy"}
+	}
+
+	review, err := ceiling.K8sClient.AuthorizationV1().SelfSubjectRulesReviews().Create(&authorizationV1.SelfSubjectRulesReview{
+		Spec: authorizationV1.SelfSubjectRulesReviewSpec{Namespace: namespace},
+	})
+	if err != nil {
+		return err
+	}
+	// an incomplete review cannot prove the service lacks a permission, the api server still prevents escalation
+	if review.Status.Incomplete {
+		return nil
+	}
+	own := ownRules(review.Status)
+	if coversResource(own, rbacV1.GroupName, resource, "", verb) {
+		return nil
+	}
+	if uncovered := UncoveredPermissions(own, rules); len(uncovered) > 0 {
+		return &EscalationError{Permissions: uncovered, Reason: "rules exceed the permissions of the service"}
+	}
+	return nil
+}
+
+func ownRules(status authorizationV1.SubjectRulesReviewStatus) []rbacV1.PolicyRule {
+	var rules []rbacV1.PolicyRule
+	for _, each := range status.ResourceRules {
+		rules = append(rules, rbacV1.PolicyRule{
+			Verbs:         each.Verbs,
+			APIGroups:     each.APIGroups,
+			Resources:     each.Resources,
+			ResourceNames: each.ResourceNames,
+		})
+	}
+	for _, each := range status.NonResourceRules {
+		rules = append(rules, rbacV1.PolicyRule{
+			Verbs:           each.Verbs,
+			NonResourceURLs: each.NonResourceURLs,
+		})
+	}
+	return rules
+}
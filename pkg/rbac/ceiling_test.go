@@ -0,0 +1,102 @@
+package rbac
+
+import (
+	"testing"
+
+	authorizationV1 "k8s.io/api/authorization/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sTesting "k8s.io/client-go/testing"
+)
+
+// fakeClientWithOwnRules answers SelfSubjectRulesReviews with the rules of the service
+func fakeClientWithOwnRules(status authorizationV1.SubjectRulesReviewStatus) *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectrulesreviews",
+		func(action k8sTesting.Action) (bool, runtime.Object, error) {
+			return true, &authorizationV1.SelfSubjectRulesReview{Status: status}, nil
+		})
+	return client
+}
+
+func TestCheckRole(t *testing.T) {
+	podsReadonly := []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}}
+	allPods := authorizationV1.SubjectRulesReviewStatus{ResourceRules: []authorizationV1.ResourceRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"*"}},
+	}}
+	tests := []struct {
+		name      string
+		ceiling   []rbacV1.PolicyRule
+		own       authorizationV1.SubjectRulesReviewStatus
+		rules     []rbacV1.PolicyRule
+		escalates bool
+	}{
+		{
+			name:  "default ceiling and own rules cover the role",
+			own:   allPods,
+			rules: podsReadonly,
+		},
+		{
+			name:      "beyond the ceiling",
+			ceiling:   podsReadonly,
+			own:       allPods,
+			rules:     []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"delete"}}},
+			escalates: true,
+		},
+		{
+			name:      "wildcard only covered by a wildcard",
+			ceiling:   podsReadonly,
+			own:       allPods,
+			rules:     []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"*"}, Verbs: []string{"get"}}},
+			escalates: true,
+		},
+		{
+			name:      "beyond the permissions of the service",
+			own:       allPods,
+			rules:     []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+			escalates: true,
+		},
+		{
+			name: "service may escalate",
+			own: authorizationV1.SubjectRulesReviewStatus{ResourceRules: []authorizationV1.ResourceRule{
+				{APIGroups: []string{rbacV1.GroupName}, Resources: []string{"roles"}, Verbs: []string{"escalate"}},
+			}},
+			rules: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+		{
+			name:  "incomplete review",
+			own:   authorizationV1.SubjectRulesReviewStatus{Incomplete: true},
+			rules: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ceiling := NewCeiling(test.ceiling, fakeClientWithOwnRules(test.own))
+			err := ceiling.CheckRole("clustar-a", test.rules)
+			if _, escalates := err.(*EscalationError); escalates != test.escalates {
+				t.Errorf("CheckRole = %v, escalation expected: %v", err, test.escalates)
+			}
+			if err != nil && !test.escalates {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdminRulesKeepQuotasReadonly(t *testing.T) {
+	rules := NewNamespaceAdminRole("clustar-a", fake.NewSimpleClientset()).role().Rules
+	for _, resource := range []string{"resourcequotas", "limitranges"} {
+		if !coversResource(rules, "", resource, "", "get") {
+			t.Errorf("admin cannot read %s", resource)
+		}
+		for _, verb := range []string{"create", "update", "patch", "delete"} {
+			if coversResource(rules, "", resource, "", verb) || coversResource(DefaultCeilingRules, "", resource, "", verb) {
+				t.Errorf("admin or the default ceiling may %s %s", verb, resource)
+			}
+		}
+	}
+	if !coversResource(rules, "apps", "deployments", "", "create") || !coversResource(rules, "", "secrets", "", "delete") {
+		t.Error("admin cannot manage the workloads of the namespace")
+	}
+}
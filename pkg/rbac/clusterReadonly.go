@@ -9,6 +9,9 @@ import (
 
 const ReadOnlyRole = "cluster-readonly"
 
+// ReadOnlyAggregationLabel selects the ClusterRoles which are aggregated into the readonly role
+var ReadOnlyAggregationLabel = "clustar.ai/aggregate-to-cluster-readonly"
+
 type ClusterReadonlyRole struct {
 	BaseRole
 	K8sClient kubernetes.Interface
@@ -22,36 +25,46 @@ func NewClusterReadonlyRoleRole(namespace, rolename string, k8sclient kubernetes
 	return
 }
 
+// the readonly role is aggregated from every ClusterRole labeled with ReadOnlyAggregationLabel,
+// its rules are filled in by the controller manager
+func (role *ClusterReadonlyRole) clusterRole() *rbacV1.ClusterRole {
+	roleTmp := &rbacV1.ClusterRole{}
+	roleTmp.APIVersion = "v1"
+	roleTmp.Kind = "ClusterRole"
+	roleTmp.Name = ReadOnlyRole
+	roleTmp.AggregationRule = &rbacV1.AggregationRule{
+		ClusterRoleSelectors: []metaV1.LabelSelector{{
+			MatchLabels: map[string]string{ReadOnlyAggregationLabel: "true"},
+		}},
+	}
+	return roleTmp
+}
+
+func (role *ClusterReadonlyRole) clusterRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) *rbacV1.ClusterRoleBinding {
+	rolebindingtmp := &rbacV1.ClusterRoleBinding{}
+	rolebindingtmp.APIVersion = "v1"
+	rolebindingtmp.Kind = "ClusterRoleBinding"
+	rolebindingtmp.Name = GenerateRoleBindingName(role.RoleName, accountNamespace, accountName)
+	rolebindingtmp.Subjects = bindingSubjects(accountNamespace, accountName, subjects)
+	rolebindingtmp.RoleRef.Kind = "ClusterRole"
+	rolebindingtmp.RoleRef.Name = ReadOnlyRole
+	return rolebindingtmp
+}
+
 func (role *ClusterReadonlyRole) CreateRole() error {
+	available, _ := availableReadonlyPieces()
+	for _, piece := range available {
+		if err := createReadonlyPieceIfNotExists(role.K8sClient, piece); err != nil {
+			return err
+		}
+	}
+
 	_, err := role.K8sClient.RbacV1().ClusterRoles().Get(ReadOnlyRole, metaV1.GetOptions{})
 	if err != nil {
 		switch t := err.(type) {
 		case *k8sError.StatusError:
 			if t.Status().Reason == metaV1.StatusReasonNotFound {
-				roleTmp := &rbacV1.ClusterRole{}
-				roleTmp.APIVersion = "v1"
-				roleTmp.Kind = "ClusterRole"
-				roleTmp.Name = ReadOnlyRole
-				roleTmp.Namespace = role.Namespace
-				roleTmp.Rules = append(roleTmp.Rules,
-					rbacV1.PolicyRule{
-						APIGroups: []string{""},
-						Resources: []string{"pods"},
-						Verbs:     []string{"get", "list", "watch"}},
-					rbacV1.PolicyRule{
-						APIGroups: []string{"batch"},
-						Resources: []string{"jobs"},
-						Verbs:     []string{"get", "list", "watch"}},
-					rbacV1.PolicyRule{
-						APIGroups: []string{""},
-						Resources: []string{"services"},
-						Verbs:     []string{"get", "list", "watch"}},
-					rbacV1.PolicyRule{
-						APIGroups: []string{"kubeflow.org"},
-						Resources: []string{"tfjobs"},
-						Verbs:     []string{"get", "list", "watch"}},
-				)
-				_, err := role.K8sClient.RbacV1().ClusterRoles().Create(roleTmp)
+				_, err := role.K8sClient.RbacV1().ClusterRoles().Create(role.clusterRole())
 				if err != nil {
 					return err
 				}
@@ -65,27 +78,14 @@ func (role *ClusterReadonlyRole) CreateRole() error {
 	return nil
 }
 
-func (role *ClusterReadonlyRole) CreateRoleBinding(accountNamespace, accountName string) error {
+func (role *ClusterReadonlyRole) CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error {
 	bindingName := GenerateRoleBindingName(role.RoleName, accountNamespace, accountName)
 	_, err := role.K8sClient.RbacV1().ClusterRoleBindings().Get(bindingName, metaV1.GetOptions{})
 	if err != nil {
 		switch t := err.(type) {
 		case *k8sError.StatusError:
 			if t.Status().Reason == metaV1.StatusReasonNotFound {
-				rolebindingtmp := &rbacV1.ClusterRoleBinding{}
-				rolebindingtmp.APIVersion = "v1"
-				rolebindingtmp.Kind = "ClusterRoleBinding"
-				rolebindingtmp.Name = bindingName
-				rolebindingtmp.Namespace = accountNamespace
-				rolebindingtmp.Subjects = append(rolebindingtmp.Subjects, rbacV1.Subject{
-					Kind:      "ServiceAccount",
-					Name:      accountName,
-					Namespace: accountNamespace,
-				})
-				rolebindingtmp.RoleRef.Kind = "ClusterRole"
-				rolebindingtmp.RoleRef.Name = ReadOnlyRole
-
-				_, err = role.K8sClient.RbacV1().ClusterRoleBindings().Create(rolebindingtmp)
+				_, err = role.K8sClient.RbacV1().ClusterRoleBindings().Create(role.clusterRoleBinding(accountNamespace, accountName, subjects))
 				if err != nil {
 					return err
 				}
@@ -99,3 +99,54 @@ func (role *ClusterReadonlyRole) CreateRoleBinding(accountNamespace, accountName
 
 	return nil
 }
+
+func (role *ClusterReadonlyRole) ReconcileRole() (bool, error) {
+	changed := false
+	available, unavailable := availableReadonlyPieces()
+	for _, piece := range available {
+		pieceChanged, err := reconcileClusterRole(role.K8sClient, piece.clusterRole())
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || pieceChanged
+	}
+	// pieces of api groups the cluster does not serve any more are taken out of the aggregation
+	for _, piece := range unavailable {
+		exists, err := clusterRoleExists(role.K8sClient, piece.clusterRole().Name)
+		if err == nil && exists {
+			err = deleteClusterRole(role.K8sClient, piece.clusterRole().Name)
+			changed = true
+		}
+		if err != nil {
+			return changed, err
+		}
+	}
+
+	roleChanged, err := reconcileClusterRole(role.K8sClient, role.clusterRole())
+	return changed || roleChanged, err
+}
+
+func (role *ClusterReadonlyRole) ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error) {
+	return reconcileClusterRoleBinding(role.K8sClient, role.clusterRoleBinding(accountNamespace, accountName, subjects))
+}
+
+func (role *ClusterReadonlyRole) DeleteRoleBinding(accountNamespace, accountName string) error {
+	return deleteClusterRoleBinding(role.K8sClient, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *ClusterReadonlyRole) RoleExists() (bool, error) {
+	return clusterRoleExists(role.K8sClient, ReadOnlyRole)
+}
+
+func (role *ClusterReadonlyRole) RoleBindingExists(accountNamespace, accountName string) (bool, error) {
+	return clusterRoleBindingExists(role.K8sClient, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *ClusterReadonlyRole) Describe() RoleDescription {
+	return RoleDescription{
+		RoleKind:    "ClusterRole",
+		RoleName:    ReadOnlyRole,
+		BindingKind: "ClusterRoleBinding",
+		Managed:     true,
+	}
+}
@@ -0,0 +1,73 @@
+package rbac
+
+import (
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CustomRole binds an account to a Role or ClusterRole which is managed outside of this service,
+// the binding is always a RoleBinding, so a ClusterRole is only granted inside the namespace
+type CustomRole struct {
+	BaseRole
+	RoleKind  string
+	K8sClient kubernetes.Interface
+}
+
+func NewCustomRole(namespace, roleKind, rolename string, k8sclient kubernetes.Interface) (role *CustomRole) {
+	role = &CustomRole{}
+	role.Namespace = namespace
+	role.RoleName = rolename
+	role.RoleKind = roleKind
+	role.K8sClient = k8sclient
+	return
+}
+
+// custom role is not created by the service, only check if it exists
+func (role *CustomRole) CreateRole() error {
+	if role.RoleKind == "ClusterRole" {
+		_, err := role.K8sClient.RbacV1().ClusterRoles().Get(role.RoleName, metaV1.GetOptions{})
+		return err
+	}
+	_, err := role.K8sClient.RbacV1().Roles(role.Namespace).Get(role.RoleName, metaV1.GetOptions{})
+	return err
+}
+
+func (role *CustomRole) CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error {
+	return createRoleBindingIfNotExists(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, role.RoleKind, role.RoleName, accountNamespace, accountName, subjects))
+}
+
+// custom role is not managed by the service, so there is nothing to reconcile
+func (role *CustomRole) ReconcileRole() (bool, error) {
+	return false, nil
+}
+
+func (role *CustomRole) ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error) {
+	return reconcileRoleBinding(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, role.RoleKind, role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *CustomRole) DeleteRoleBinding(accountNamespace, accountName string) error {
+	return deleteRoleBinding(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *CustomRole) RoleExists() (bool, error) {
+	if role.RoleKind == "ClusterRole" {
+		return clusterRoleExists(role.K8sClient, role.RoleName)
+	}
+	return roleExists(role.K8sClient, role.Namespace, role.RoleName)
+}
+
+func (role *CustomRole) RoleBindingExists(accountNamespace, accountName string) (bool, error) {
+	return roleBindingExists(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *CustomRole) Describe() RoleDescription {
+	description := namespacedRoleDescription(role.Namespace, role.RoleName, false)
+	if role.RoleKind == "ClusterRole" {
+		description.RoleKind = "ClusterRole"
+		description.RoleNamespace = ""
+	}
+	return description
+}
@@ -0,0 +1,108 @@
+package rbac
+
+import (
+	"fmt"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// HelmModeV2 binds accounts to the tiller role, when the tiller role exists
+	HelmModeV2 = "helm2"
+	// HelmModeV3 lets accounts manage the release secrets helm 3 keeps in their namespace
+	HelmModeV3 = "helm3"
+	// HelmModeDisabled does not grant anything for helm
+	HelmModeDisabled = "disabled"
+
+	DefaultHelmMode = HelmModeV2
+
+	helmRoleNamePattern = "%s:helm"
+)
+
+func IsValidHelmMode(mode string) bool {
+	switch mode {
+	case HelmModeV2, HelmModeV3, HelmModeDisabled:
+		return true
+	}
+	return false
+}
+
+// helmRoles returns the roles the helm integration binds to accounts of options.Namespace
+func helmRoles(options ProfileOptions, k8sClient kubernetes.Interface) []RbacInterface {
+	switch options.HelmMode {
+	case HelmModeV3:
+		return []RbacInterface{NewHelmReleaseRole(options.Namespace, k8sClient)}
+	case HelmModeDisabled:
+		return nil
+	}
+	if !TillerAvailable() {
+		return nil
+	}
+	role := NewTillerRole(options.TillerNamespace, options.TillerRole, k8sClient)
+	role.Optional = true
+	return []RbacInterface{role}
+}
+
+// HelmReleaseRole grants access to the secrets helm 3 stores its releases in
+type HelmReleaseRole struct {
+	BaseRole
+	K8sClient kubernetes.Interface
+}
+
+func NewHelmReleaseRole(namespace string, k8sclient kubernetes.Interface) (role *HelmReleaseRole) {
+	role = &HelmReleaseRole{}
+	role.Namespace = namespace
+	role.RoleName = fmt.Sprintf(helmRoleNamePattern, namespace)
+	role.K8sClient = k8sclient
+	return
+}
+
+func (role *HelmReleaseRole) role() *rbacV1.Role {
+	roleTmp := &rbacV1.Role{}
+	roleTmp.APIVersion = "v1"
+	roleTmp.Kind = "Role"
+	roleTmp.Name = role.RoleName
+	roleTmp.Namespace = role.Namespace
+	roleTmp.Rules = append(roleTmp.Rules,
+		rbacV1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"secrets"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+	)
+	return roleTmp
+}
+
+func (role *HelmReleaseRole) CreateRole() error {
+	return createRoleIfNotExists(role.K8sClient, role.role())
+}
+
+func (role *HelmReleaseRole) CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error {
+	return createRoleBindingIfNotExists(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *HelmReleaseRole) ReconcileRole() (bool, error) {
+	return reconcileRole(role.K8sClient, role.role())
+}
+
+func (role *HelmReleaseRole) ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error) {
+	return reconcileRoleBinding(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *HelmReleaseRole) DeleteRoleBinding(accountNamespace, accountName string) error {
+	return deleteRoleBinding(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *HelmReleaseRole) RoleExists() (bool, error) {
+	return roleExists(role.K8sClient, role.Namespace, role.RoleName)
+}
+
+func (role *HelmReleaseRole) RoleBindingExists(accountNamespace, accountName string) (bool, error) {
+	return roleBindingExists(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *HelmReleaseRole) Describe() RoleDescription {
+	return namespacedRoleDescription(role.Namespace, role.RoleName, true)
+}
@@ -0,0 +1,220 @@
+package rbac
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const serviceAccountGroupPrefix = "system:serviceaccounts:"
+
+// PermissionEntry is one row of the permission matrix, the verbs a subject has on a resource
+// and the bindings which grant them
+type PermissionEntry struct {
+	Subject      rbacV1.Subject
+	APIGroup     string
+	Resource     string
+	ResourceName string
+	Verbs        []string
+	Bindings     []string
+}
+
+// SubjectString formats a subject as Kind/Namespace/Name, or Kind/Name for subjects without namespace
+func SubjectString(subject rbacV1.Subject) string {
+	if subject.Namespace == "" {
+		return fmt.Sprintf("%s/%s", subject.Kind, subject.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", subject.Kind, subject.Namespace, subject.Name)
+}
+
+type permissionKey struct {
+	subject      string
+	apiGroup     string
+	resource     string
+	resourceName string
+}
+
+type permissionMatrix struct {
+	subjects map[string]rbacV1.Subject
+	verbs    map[permissionKey]map[string]bool
+	bindings map[permissionKey]map[string]bool
+}
+
+func (matrix *permissionMatrix) add(subject rbacV1.Subject, rules []rbacV1.PolicyRule, binding string) {
+	subject = normalizeSubject(subject)
+	subjectKey := SubjectString(subject)
+	matrix.subjects[subjectKey] = subject
+	for _, rule := range rules {
+		names := rule.ResourceNames
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, name := range names {
+					key := permissionKey{subject: subjectKey, apiGroup: group, resource: resource, resourceName: name}
+					if matrix.verbs[key] == nil {
+						matrix.verbs[key] = map[string]bool{}
+						matrix.bindings[key] = map[string]bool{}
+					}
+					for _, verb := range rule.Verbs {
+						matrix.verbs[key][verb] = true
+					}
+					matrix.bindings[key][binding] = true
+				}
+			}
+		}
+	}
+}
+
+// EffectivePermissions computes who can do what inside namespace. It walks the RoleBindings of the namespace
+// and all ClusterRoleBindings, resolves their roles, including aggregated ClusterRoles, and expands
+// the service account groups of namespaces into their service accounts. It only reads from the api server.
+func EffectivePermissions(k8sClient kubernetes.Interface, namespace string) ([]PermissionEntry, error) {
+	clusterRoles, err := k8sClient.RbacV1().ClusterRoles().List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	roles, err := k8sClient.RbacV1().Roles(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	roleBindings, err := k8sClient.RbacV1().RoleBindings(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	clusterRoleBindings, err := k8sClient.RbacV1().ClusterRoleBindings().List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	roleRules := map[string][]rbacV1.PolicyRule{}
+	for _, each := range roles.Items {
+		roleRules[each.Name] = each.Rules
+	}
+	clusterRoleRules := map[string][]rbacV1.PolicyRule{}
+	for _, each := range clusterRoles.Items {
+		rules, err := aggregatedRules(each, clusterRoles.Items)
+		if err != nil {
+			return nil, err
+		}
+		clusterRoleRules[each.Name] = rules
+	}
+
+	matrix := &permissionMatrix{
+		subjects: map[string]rbacV1.Subject{},
+		verbs:    map[permissionKey]map[string]bool{},
+		bindings: map[permissionKey]map[string]bool{},
+	}
+	expanded := map[string][]rbacV1.Subject{}
+	expand := func(subject rbacV1.Subject) ([]rbacV1.Subject, error) {
+		if subject.Kind != rbacV1.GroupKind || !strings.HasPrefix(subject.Name, serviceAccountGroupPrefix) {
+			return []rbacV1.Subject{subject}, nil
+		}
+		if subjects, ok := expanded[subject.Name]; ok {
+			return subjects, nil
+		}
+		serviceAccounts, err := k8sClient.CoreV1().ServiceAccounts(
+			strings.TrimPrefix(subject.Name, serviceAccountGroupPrefix)).List(metaV1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		subjects := []rbacV1.Subject{subject}
+		for _, each := range serviceAccounts.Items {
+			subjects = append(subjects, rbacV1.Subject{
+				Kind:      rbacV1.ServiceAccountKind,
+				Name:      each.Name,
+				Namespace: each.Namespace,
+			})
+		}
+		expanded[subject.Name] = subjects
+		return subjects, nil
+	}
+
+	addBinding := func(subjects []rbacV1.Subject, rules []rbacV1.PolicyRule, binding string) error {
+		for _, subject := range subjects {
+			each, err := expand(subject)
+			if err != nil {
+				return err
+			}
+			for _, one := range each {
+				matrix.add(one, rules, binding)
+			}
+		}
+		return nil
+	}
+
+	for _, each := range roleBindings.Items {
+		rules := roleRules[each.RoleRef.Name]
+		if each.RoleRef.Kind == "ClusterRole" {
+			rules = clusterRoleRules[each.RoleRef.Name]
+		}
+		if err := addBinding(each.Subjects, rules, "RoleBinding/"+each.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, each := range clusterRoleBindings.Items {
+		if err := addBinding(each.Subjects, clusterRoleRules[each.RoleRef.Name], "ClusterRoleBinding/"+each.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []PermissionEntry
+	for key, verbs := range matrix.verbs {
+		entry := PermissionEntry{
+			Subject:      matrix.subjects[key.subject],
+			APIGroup:     key.apiGroup,
+			Resource:     key.resource,
+			ResourceName: key.resourceName,
+		}
+		for verb := range verbs {
+			entry.Verbs = append(entry.Verbs, verb)
+		}
+		for binding := range matrix.bindings[key] {
+			entry.Bindings = append(entry.Bindings, binding)
+		}
+		sort.Strings(entry.Verbs)
+		sort.Strings(entry.Bindings)
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if SubjectString(a.Subject) != SubjectString(b.Subject) {
+			return SubjectString(a.Subject) < SubjectString(b.Subject)
+		}
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.ResourceName < b.ResourceName
+	})
+	return entries, nil
+}
+
+// aggregatedRules returns the rules of a ClusterRole together with the rules of the ClusterRoles
+// its aggregation rule selects, in case the controller manager has not filled them in yet
+func aggregatedRules(role rbacV1.ClusterRole, clusterRoles []rbacV1.ClusterRole) ([]rbacV1.PolicyRule, error) {
+	rules := append([]rbacV1.PolicyRule{}, role.Rules...)
+	if role.AggregationRule == nil {
+		return rules, nil
+	}
+	for _, each := range role.AggregationRule.ClusterRoleSelectors {
+		selector, err := metaV1.LabelSelectorAsSelector(&each)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range clusterRoles {
+			if other.Name != role.Name && selector.Matches(labels.Set(other.Labels)) {
+				rules = append(rules, other.Rules...)
+			}
+		}
+	}
+	return rules, nil
+}
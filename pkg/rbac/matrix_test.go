@@ -0,0 +1,113 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEffectivePermissions(t *testing.T) {
+	namespace := "clustar-a"
+	alice := rbacV1.Subject{Kind: rbacV1.UserKind, APIGroup: rbacV1.GroupName, Name: "alice"}
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		want    []PermissionEntry
+	}{
+		{
+			name: "role binding to a role",
+			objects: []runtime.Object{
+				&rbacV1.Role{
+					ObjectMeta: metaV1.ObjectMeta{Name: "pod-reader", Namespace: namespace},
+					Rules:      []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "get"}}},
+				},
+				&rbacV1.RoleBinding{
+					ObjectMeta: metaV1.ObjectMeta{Name: "read-pods", Namespace: namespace},
+					Subjects:   []rbacV1.Subject{alice},
+					RoleRef:    rbacV1.RoleRef{Kind: "Role", Name: "pod-reader"},
+				},
+			},
+			want: []PermissionEntry{
+				{Subject: alice, APIGroup: "", Resource: "pods", Verbs: []string{"get", "list"}, Bindings: []string{"RoleBinding/read-pods"}},
+			},
+		},
+		{
+			name: "role binding to an aggregated cluster role",
+			objects: []runtime.Object{
+				&rbacV1.ClusterRole{
+					ObjectMeta: metaV1.ObjectMeta{Name: "aggregated"},
+					AggregationRule: &rbacV1.AggregationRule{ClusterRoleSelectors: []metaV1.LabelSelector{{
+						MatchLabels: map[string]string{"aggregate-to": "aggregated"},
+					}}},
+				},
+				&rbacV1.ClusterRole{
+					ObjectMeta: metaV1.ObjectMeta{Name: "piece", Labels: map[string]string{"aggregate-to": "aggregated"}},
+					Rules:      []rbacV1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}},
+				},
+				&rbacV1.RoleBinding{
+					ObjectMeta: metaV1.ObjectMeta{Name: "aggregated", Namespace: namespace},
+					Subjects:   []rbacV1.Subject{alice},
+					RoleRef:    rbacV1.RoleRef{Kind: "ClusterRole", Name: "aggregated"},
+				},
+			},
+			want: []PermissionEntry{
+				{Subject: alice, APIGroup: "apps", Resource: "deployments", Verbs: []string{"get"}, Bindings: []string{"RoleBinding/aggregated"}},
+			},
+		},
+		{
+			name: "cluster role binding to the service account group of the namespace",
+			objects: []runtime.Object{
+				&coreV1.ServiceAccount{ObjectMeta: metaV1.ObjectMeta{Name: "bob", Namespace: namespace}},
+				&rbacV1.ClusterRole{
+					ObjectMeta: metaV1.ObjectMeta{Name: "viewer"},
+					Rules:      []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: []string{"list"}}},
+				},
+				&rbacV1.ClusterRoleBinding{
+					ObjectMeta: metaV1.ObjectMeta{Name: "viewers"},
+					Subjects:   []rbacV1.Subject{{Kind: rbacV1.GroupKind, APIGroup: rbacV1.GroupName, Name: "system:serviceaccounts:" + namespace}},
+					RoleRef:    rbacV1.RoleRef{Kind: "ClusterRole", Name: "viewer"},
+				},
+			},
+			want: []PermissionEntry{
+				{
+					Subject:  rbacV1.Subject{Kind: rbacV1.GroupKind, APIGroup: rbacV1.GroupName, Name: "system:serviceaccounts:" + namespace},
+					Resource: "services", Verbs: []string{"list"}, Bindings: []string{"ClusterRoleBinding/viewers"},
+				},
+				{
+					Subject:  rbacV1.Subject{Kind: rbacV1.ServiceAccountKind, Name: "bob", Namespace: namespace},
+					Resource: "services", Verbs: []string{"list"}, Bindings: []string{"ClusterRoleBinding/viewers"},
+				},
+			},
+		},
+		{
+			name: "bindings of other namespaces are ignored",
+			objects: []runtime.Object{
+				&rbacV1.Role{
+					ObjectMeta: metaV1.ObjectMeta{Name: "pod-reader", Namespace: "clustar-b"},
+					Rules:      []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}},
+				},
+				&rbacV1.RoleBinding{
+					ObjectMeta: metaV1.ObjectMeta{Name: "read-pods", Namespace: "clustar-b"},
+					Subjects:   []rbacV1.Subject{alice},
+					RoleRef:    rbacV1.RoleRef{Kind: "Role", Name: "pod-reader"},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := EffectivePermissions(fake.NewSimpleClientset(test.objects...), namespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(entries, test.want) {
+				t.Errorf("EffectivePermissions = %+v, want %+v", entries, test.want)
+			}
+		})
+	}
+}
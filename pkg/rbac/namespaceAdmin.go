@@ -2,14 +2,32 @@ package rbac
 
 import (
 	"fmt"
+
 	rbacV1 "k8s.io/api/rbac/v1"
-	k8sError "k8s.io/apimachinery/pkg/api/errors"
-	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 const adminRoleNamePattern = "%s:admin"
 
+// adminCoreResources are the resources of the core group admins manage. Quotas and limit ranges are only
+// read, they enforce the storage budget and a tenant must not lift them.
+var adminCoreResources = []string{"pods", "pods/attach", "pods/exec", "pods/log", "pods/portforward", "pods/proxy",
+	"services", "services/proxy", "endpoints", "configmaps", "secrets", "serviceaccounts", "persistentvolumeclaims",
+	"replicationcontrollers", "replicationcontrollers/scale", "podtemplates", "events"}
+
+// adminAPIGroups are the api groups admins manage entirely, network attachments included
+var adminAPIGroups = []string{"apps", "batch", "extensions", "autoscaling", "policy", "networking.k8s.io",
+	rbacV1.GroupName, KubeflowAPIGroup, "k8s.cni.cncf.io"}
+
+// adminRules gives everything inside the namespace but writing its quotas and limit ranges
+func adminRules(groups []string) []rbacV1.PolicyRule {
+	return []rbacV1.PolicyRule{
+		{APIGroups: []string{""}, Resources: adminCoreResources, Verbs: []string{"*"}},
+		{APIGroups: []string{""}, Resources: []string{"resourcequotas", "limitranges"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: groups, Resources: []string{"*"}, Verbs: []string{"*"}},
+	}
+}
+
 type NamespaceAdminRole struct {
 	BaseRole
 	K8sClient kubernetes.Interface
@@ -23,74 +41,50 @@ func NewNamespaceAdminRole(namespace string, k8sclient kubernetes.Interface) (ro
 	return
 }
 
+func (role *NamespaceAdminRole) role() *rbacV1.Role {
+	roleTmp := &rbacV1.Role{}
+	roleTmp.APIVersion = "v1"
+	roleTmp.Kind = "Role"
+	roleTmp.Name = role.RoleName
+	roleTmp.Namespace = role.Namespace
+	roleTmp.Rules = adminRules(availableAPIGroups(adminAPIGroups...))
+	return roleTmp
+}
+
 func (role *NamespaceAdminRole) CreateRole() error {
-	adminRoleName := generateAdminRoleName(role.Namespace)
-	_, err := role.K8sClient.RbacV1().Roles(role.Namespace).Get(adminRoleName, metaV1.GetOptions{})
-	if err != nil {
-		switch t := err.(type) {
-		case *k8sError.StatusError:
-			if t.Status().Reason == metaV1.StatusReasonNotFound {
-				roleTmp := &rbacV1.Role{}
-				roleTmp.APIVersion = "v1"
-				roleTmp.APIVersion = "v1"
-				roleTmp.Kind = "Role"
-				roleTmp.Name = adminRoleName
-				roleTmp.Namespace = role.Namespace
-				roleTmp.Rules = append(roleTmp.Rules,
-					rbacV1.PolicyRule{
-						APIGroups: []string{"*"},
-						Resources: []string{"*"},
-						Verbs:     []string{"*"}},
-				)
-				_, err := role.K8sClient.RbacV1().Roles(role.Namespace).Create(roleTmp)
-				if err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		default:
-			return err
-		}
-	}
-	return nil
+	return createRoleIfNotExists(role.K8sClient, role.role())
 }
 
-func (role *NamespaceAdminRole) CreateRoleBinding(accountNamespace, accountName string) error {
-	bindingName := GenerateRoleBindingName(role.RoleName, accountNamespace, accountName)
-	_, err := role.K8sClient.RbacV1().RoleBindings(role.Namespace).Get(bindingName, metaV1.GetOptions{})
-	if err != nil {
-		switch t := err.(type) {
-		case *k8sError.StatusError:
-			if t.Status().Reason == metaV1.StatusReasonNotFound {
-				rolebindingtmp := &rbacV1.RoleBinding{}
-				rolebindingtmp.APIVersion = "v1"
-				rolebindingtmp.Kind = "RoleBinding"
-				rolebindingtmp.Name = bindingName
-				rolebindingtmp.Namespace = role.Namespace
-				rolebindingtmp.Subjects = append(rolebindingtmp.Subjects, rbacV1.Subject{
-					Kind:      "ServiceAccount",
-					Name:      accountName,
-					Namespace: accountNamespace,
-				})
-				rolebindingtmp.RoleRef.Kind = "Role"
-				rolebindingtmp.RoleRef.Name = role.RoleName
-
-				_, err = role.K8sClient.RbacV1().RoleBindings(role.Namespace).Create(rolebindingtmp)
-				if err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		default:
-			return err
-		}
-	}
+func (role *NamespaceAdminRole) CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error {
+	return createRoleBindingIfNotExists(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
 
-	return nil
+func (role *NamespaceAdminRole) ReconcileRole() (bool, error) {
+	return reconcileRole(role.K8sClient, role.role())
+}
+
+func (role *NamespaceAdminRole) ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error) {
+	return reconcileRoleBinding(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
 }
 
 func generateAdminRoleName(namespace string) string {
 	return fmt.Sprintf(adminRoleNamePattern, namespace)
 }
+
+func (role *NamespaceAdminRole) DeleteRoleBinding(accountNamespace, accountName string) error {
+	return deleteRoleBinding(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *NamespaceAdminRole) RoleExists() (bool, error) {
+	return roleExists(role.K8sClient, role.Namespace, role.RoleName)
+}
+
+func (role *NamespaceAdminRole) RoleBindingExists(accountNamespace, accountName string) (bool, error) {
+	return roleBindingExists(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *NamespaceAdminRole) Describe() RoleDescription {
+	return namespacedRoleDescription(role.Namespace, role.RoleName, true)
+}
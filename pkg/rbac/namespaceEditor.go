@@ -0,0 +1,100 @@
+package rbac
+
+import (
+	"fmt"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const editorRoleNamePattern = "%s:editor"
+
+// editorWorkloads are the resources editors manage, by api group. Secrets, service accounts and the exec
+// and attach subresources of pods are left to the admin profile, quotas and limit ranges to nobody.
+var editorWorkloads = []struct {
+	APIGroup  string
+	Resources []string
+}{
+	{APIGroup: "", Resources: []string{"pods", "pods/log", "pods/portforward", "services", "endpoints",
+		"configmaps", "persistentvolumeclaims", "replicationcontrollers", "replicationcontrollers/scale"}},
+	{APIGroup: "apps", Resources: []string{"deployments", "deployments/scale", "replicasets", "replicasets/scale",
+		"statefulsets", "statefulsets/scale", "daemonsets"}},
+	{APIGroup: "batch", Resources: []string{"jobs", "cronjobs"}},
+	{APIGroup: "extensions", Resources: []string{"deployments", "deployments/scale", "replicasets", "replicasets/scale",
+		"daemonsets", "ingresses"}},
+	{APIGroup: "autoscaling", Resources: []string{"horizontalpodautoscalers"}},
+	{APIGroup: KubeflowAPIGroup, Resources: []string{"tfjobs"}},
+}
+
+// NamespaceEditorRole can manage workloads in the namespace, but cannot touch rbac objects
+type NamespaceEditorRole struct {
+	BaseRole
+	K8sClient kubernetes.Interface
+}
+
+func NewNamespaceEditorRole(namespace string, k8sclient kubernetes.Interface) (role *NamespaceEditorRole) {
+	role = &NamespaceEditorRole{}
+	role.Namespace = namespace
+	role.RoleName = fmt.Sprintf(editorRoleNamePattern, namespace)
+	role.K8sClient = k8sclient
+	return
+}
+
+func (role *NamespaceEditorRole) role() *rbacV1.Role {
+	roleTmp := &rbacV1.Role{}
+	roleTmp.APIVersion = "v1"
+	roleTmp.Kind = "Role"
+	roleTmp.Name = role.RoleName
+	roleTmp.Namespace = role.Namespace
+	for _, workloads := range editorWorkloads {
+		if !APIGroupAvailable(workloads.APIGroup) {
+			continue
+		}
+		roleTmp.Rules = append(roleTmp.Rules, rbacV1.PolicyRule{
+			APIGroups: []string{workloads.APIGroup},
+			Resources: workloads.Resources,
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete", "deletecollection"}})
+	}
+	// events and the quotas of the namespace are only read, the storage budget is enforced by a quota
+	roleTmp.Rules = append(roleTmp.Rules,
+		rbacV1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"events", "resourcequotas", "limitranges"},
+			Verbs:     []string{"get", "list", "watch"}},
+	)
+	return roleTmp
+}
+
+func (role *NamespaceEditorRole) CreateRole() error {
+	return createRoleIfNotExists(role.K8sClient, role.role())
+}
+
+func (role *NamespaceEditorRole) CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error {
+	return createRoleBindingIfNotExists(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *NamespaceEditorRole) ReconcileRole() (bool, error) {
+	return reconcileRole(role.K8sClient, role.role())
+}
+
+func (role *NamespaceEditorRole) ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error) {
+	return reconcileRoleBinding(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *NamespaceEditorRole) DeleteRoleBinding(accountNamespace, accountName string) error {
+	return deleteRoleBinding(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *NamespaceEditorRole) RoleExists() (bool, error) {
+	return roleExists(role.K8sClient, role.Namespace, role.RoleName)
+}
+
+func (role *NamespaceEditorRole) RoleBindingExists(accountNamespace, accountName string) (bool, error) {
+	return roleBindingExists(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *NamespaceEditorRole) Describe() RoleDescription {
+	return namespacedRoleDescription(role.Namespace, role.RoleName, true)
+}
@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"fmt"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const viewerRoleNamePattern = "%s:viewer"
+
+// NamespaceViewerRole can read workloads in the namespace, but not secrets
+type NamespaceViewerRole struct {
+	BaseRole
+	K8sClient kubernetes.Interface
+}
+
+func NewNamespaceViewerRole(namespace string, k8sclient kubernetes.Interface) (role *NamespaceViewerRole) {
+	role = &NamespaceViewerRole{}
+	role.Namespace = namespace
+	role.RoleName = fmt.Sprintf(viewerRoleNamePattern, namespace)
+	role.K8sClient = k8sclient
+	return
+}
+
+func (role *NamespaceViewerRole) role() *rbacV1.Role {
+	roleTmp := &rbacV1.Role{}
+	roleTmp.APIVersion = "v1"
+	roleTmp.Kind = "Role"
+	roleTmp.Name = role.RoleName
+	roleTmp.Namespace = role.Namespace
+	roleTmp.Rules = append(roleTmp.Rules,
+		rbacV1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"pods", "pods/log", "services", "endpoints", "configmaps",
+				"persistentvolumeclaims", "events", "serviceaccounts", "resourcequotas", "limitranges"},
+			Verbs: []string{"get", "list", "watch"}},
+		rbacV1.PolicyRule{
+			APIGroups: availableAPIGroups("apps", "batch", "extensions", KubeflowAPIGroup),
+			Resources: []string{"*"},
+			Verbs:     []string{"get", "list", "watch"}},
+	)
+	return roleTmp
+}
+
+func (role *NamespaceViewerRole) CreateRole() error {
+	return createRoleIfNotExists(role.K8sClient, role.role())
+}
+
+func (role *NamespaceViewerRole) CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error {
+	return createRoleBindingIfNotExists(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *NamespaceViewerRole) ReconcileRole() (bool, error) {
+	return reconcileRole(role.K8sClient, role.role())
+}
+
+func (role *NamespaceViewerRole) ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error) {
+	return reconcileRoleBinding(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *NamespaceViewerRole) DeleteRoleBinding(accountNamespace, accountName string) error {
+	return deleteRoleBinding(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *NamespaceViewerRole) RoleExists() (bool, error) {
+	return roleExists(role.K8sClient, role.Namespace, role.RoleName)
+}
+
+func (role *NamespaceViewerRole) RoleBindingExists(accountNamespace, accountName string) (bool, error) {
+	return roleBindingExists(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *NamespaceViewerRole) Describe() RoleDescription {
+	return namespacedRoleDescription(role.Namespace, role.RoleName, true)
+}
@@ -0,0 +1,148 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ProfileViewer = "viewer"
+	ProfileEditor = "editor"
+	ProfileAdmin  = "admin"
+	ProfileCustom = "custom"
+
+	DefaultProfile = ProfileAdmin
+
+	// ProfileAnnotation records the profiles of an account on its ServiceAccount,
+	// the custom annotations record the roles bound by the custom profile
+	ProfileAnnotation           = "clustar.ai/profiles"
+	CustomRoleAnnotation        = "clustar.ai/custom-role"
+	CustomClusterRoleAnnotation = "clustar.ai/custom-clusterrole"
+)
+
+// ProfileOptions carries everything needed to resolve profiles of an account in namespace Namespace
+type ProfileOptions struct {
+	Namespace         string
+	HelmMode          string
+	TillerNamespace   string
+	TillerRole        string
+	CustomRole        string
+	CustomClusterRole string
+}
+
+func IsValidProfile(profile string) bool {
+	switch profile {
+	case ProfileViewer, ProfileEditor, ProfileAdmin, ProfileCustom:
+		return true
+	}
+	return false
+}
+
+// ResolveProfiles returns the roles which should be bound to an account with the given profiles,
+// roles shared by several profiles are only returned once
+func ResolveProfiles(profiles []string, options ProfileOptions, k8sClient kubernetes.Interface) ([]RbacInterface, error) {
+	if len(profiles) == 0 {
+		profiles = []string{DefaultProfile}
+	}
+
+	var roles []RbacInterface
+	seen := map[string]bool{}
+	add := func(role RbacInterface) {
+		key := fmt.Sprintf("%T/%s", role, role.GetRoleName())
+		if !seen[key] {
+			seen[key] = true
+			roles = append(roles, role)
+		}
+	}
+
+	for _, profile := range profiles {
+		namespaceRoles, err := ResolveNamespaceProfile(profile, options, k8sClient)
+		if err != nil {
+			return nil, err
+		}
+		for _, role := range namespaceRoles {
+			add(role)
+		}
+
+		switch profile {
+		case ProfileViewer:
+			add(NewClusterReadonlyRoleRole(options.Namespace, ReadOnlyRole, k8sClient))
+		case ProfileEditor, ProfileAdmin:
+			add(NewClusterReadonlyRoleRole(options.Namespace, ReadOnlyRole, k8sClient))
+			for _, role := range helmRoles(options, k8sClient) {
+				add(role)
+			}
+		}
+	}
+	return roles, nil
+}
+
+// ProfilesOfServiceAccount reads the profiles recorded on an account,
+// accounts provisioned before profiles were recorded have the default profile
+func ProfilesOfServiceAccount(serviceAccount *coreV1.ServiceAccount) (profiles []string, customRole, customClusterRole string) {
+	value := serviceAccount.Annotations[ProfileAnnotation]
+	if value == "" {
+		return []string{DefaultProfile}, "", ""
+	}
+	return strings.Split(value, ","),
+		serviceAccount.Annotations[CustomRoleAnnotation],
+		serviceAccount.Annotations[CustomClusterRoleAnnotation]
+}
+
+// ResolveNamespaceProfile returns the roles of the profile which live inside options.Namespace,
+// they are used on their own to grant an account access to another namespace
+func ResolveNamespaceProfile(profile string, options ProfileOptions, k8sClient kubernetes.Interface) ([]RbacInterface, error) {
+	switch profile {
+	case ProfileViewer:
+		return []RbacInterface{NewNamespaceViewerRole(options.Namespace, k8sClient)}, nil
+	case ProfileEditor:
+		return []RbacInterface{NewNamespaceEditorRole(options.Namespace, k8sClient)}, nil
+	case ProfileAdmin:
+		return []RbacInterface{NewNamespaceAdminRole(options.Namespace, k8sClient)}, nil
+	case ProfileCustom:
+		if options.CustomRole == "" && options.CustomClusterRole == "" {
+			return nil, errors.New("profile custom requires a role or a cluster role")
+		}
+		var roles []RbacInterface
+		if options.CustomRole != "" {
+			roles = append(roles, NewCustomRole(options.Namespace, "Role", options.CustomRole, k8sClient))
+		}
+		if options.CustomClusterRole != "" {
+			roles = append(roles, NewCustomRole(options.Namespace, "ClusterRole", options.CustomClusterRole, k8sClient))
+		}
+		return roles, nil
+	}
+	return nil, fmt.Errorf("unknown profile: %s", profile)
+}
+
+// ProfileOfRoleRef tells which profile a RoleBinding inside namespace was created for
+func ProfileOfRoleRef(namespace string, roleRef rbacV1.RoleRef) string {
+	if roleRef.Kind == "Role" {
+		switch roleRef.Name {
+		case fmt.Sprintf(viewerRoleNamePattern, namespace):
+			return ProfileViewer
+		case fmt.Sprintf(editorRoleNamePattern, namespace):
+			return ProfileEditor
+		case generateAdminRoleName(namespace):
+			return ProfileAdmin
+		}
+	}
+	return ProfileCustom
+}
+
+// IsManagedRoleName tells if a Role inside namespace is one of the profile roles maintained by the service
+func IsManagedRoleName(namespace, roleName string) bool {
+	switch roleName {
+	case fmt.Sprintf(viewerRoleNamePattern, namespace),
+		fmt.Sprintf(editorRoleNamePattern, namespace),
+		fmt.Sprintf(helmRoleNamePattern, namespace),
+		generateAdminRoleName(namespace):
+		return true
+	}
+	return false
+}
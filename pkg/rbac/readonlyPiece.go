@@ -0,0 +1,109 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const readonlyPieceNamePattern = ReadOnlyRole + ":%s"
+
+// ReadonlyPiece is a ClusterRole aggregated into the readonly role, it grants read access to some resources
+type ReadonlyPiece struct {
+	Name      string
+	APIGroups []string
+	Resources []string
+}
+
+// DefaultReadonlyPieces are the kinds the readonly role has always granted
+var DefaultReadonlyPieces = []ReadonlyPiece{
+	{Name: "core", APIGroups: []string{""}, Resources: []string{"pods", "services"}},
+	{Name: "batch", APIGroups: []string{"batch"}, Resources: []string{"jobs"}},
+	{Name: "kubeflow", APIGroups: []string{KubeflowAPIGroup}, Resources: []string{"tfjobs"}},
+}
+
+func (piece ReadonlyPiece) clusterRole() *rbacV1.ClusterRole {
+	roleTmp := &rbacV1.ClusterRole{}
+	roleTmp.APIVersion = "v1"
+	roleTmp.Kind = "ClusterRole"
+	roleTmp.Name = fmt.Sprintf(readonlyPieceNamePattern, piece.Name)
+	roleTmp.Labels = map[string]string{ReadOnlyAggregationLabel: "true"}
+	roleTmp.Rules = append(roleTmp.Rules,
+		rbacV1.PolicyRule{
+			APIGroups: piece.APIGroups,
+			Resources: piece.Resources,
+			Verbs:     []string{"get", "list", "watch"}},
+	)
+	return roleTmp
+}
+
+// Validate refuses pieces which would let every tenant read secrets or everything of the cluster
+func (piece ReadonlyPiece) Validate() error {
+	if piece.Name == "" || strings.Contains(piece.Name, ":") {
+		return errors.New("name of the piece is required and must not contain a colon")
+	}
+	if len(piece.APIGroups) == 0 || len(piece.Resources) == 0 {
+		return errors.New("a piece needs at least one api group and one resource")
+	}
+	for _, group := range piece.APIGroups {
+		if group == rbacV1.APIGroupAll {
+			return errors.New("api group * cannot be made readable")
+		}
+	}
+	for _, resource := range piece.Resources {
+		if resource == rbacV1.ResourceAll || resource == "secrets" || strings.HasPrefix(resource, "secrets/") {
+			return fmt.Errorf("resource %s cannot be made readable", resource)
+		}
+	}
+	return nil
+}
+
+func createReadonlyPieceIfNotExists(k8sClient kubernetes.Interface, piece ReadonlyPiece) error {
+	roleTmp := piece.clusterRole()
+	_, err := k8sClient.RbacV1().ClusterRoles().Get(roleTmp.Name, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		_, err = k8sClient.RbacV1().ClusterRoles().Create(roleTmp)
+	}
+	return err
+}
+
+// CreateReadonlyPiece registers more readable resources, the readonly role picks them up through aggregation
+func CreateReadonlyPiece(k8sClient kubernetes.Interface, piece ReadonlyPiece) (*rbacV1.ClusterRole, error) {
+	if err := piece.Validate(); err != nil {
+		return nil, err
+	}
+	return k8sClient.RbacV1().ClusterRoles().Create(piece.clusterRole())
+}
+
+// ListReadonlyPieces returns the ClusterRoles aggregated into the readonly role
+func ListReadonlyPieces(k8sClient kubernetes.Interface) ([]rbacV1.ClusterRole, error) {
+	roles, err := k8sClient.RbacV1().ClusterRoles().List(metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", ReadOnlyAggregationLabel),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roles.Items, nil
+}
+
+func IsDefaultReadonlyPiece(name string) bool {
+	for _, piece := range DefaultReadonlyPieces {
+		if piece.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteReadonlyPiece removes a registered piece, the default pieces cannot be removed
+func DeleteReadonlyPiece(k8sClient kubernetes.Interface, name string) error {
+	if IsDefaultReadonlyPiece(name) {
+		return fmt.Errorf("piece %s is a default piece of the readonly role", name)
+	}
+	return k8sClient.RbacV1().ClusterRoles().Delete(fmt.Sprintf(readonlyPieceNamePattern, name), &metaV1.DeleteOptions{})
+}
@@ -0,0 +1,32 @@
+package rbac
+
+import (
+	"fmt"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+)
+
+// ReconcileAccount brings the roles and the bindings of the account back to their templates,
+// it returns a description of every object which has been changed
+func ReconcileAccount(roles []RbacInterface, accountNamespace, accountName string, subjects []rbacV1.Subject) ([]string, error) {
+	var changes []string
+	for _, role := range roles {
+		changed, err := role.ReconcileRole()
+		if err != nil {
+			return changes, err
+		}
+		if changed {
+			changes = append(changes, fmt.Sprintf("role %s", role.GetRoleName()))
+		}
+
+		changed, err = role.ReconcileRoleBinding(accountNamespace, accountName, subjects)
+		if err != nil {
+			return changes, err
+		}
+		if changed {
+			changes = append(changes, fmt.Sprintf("binding %s",
+				GenerateRoleBindingName(role.GetRoleName(), accountNamespace, accountName)))
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,251 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Registry knows every role the service binds to accounts. Provisioning, granting and deprovisioning
+// all go through it, so whatever one of them binds the others are able to find and remove.
+type Registry struct {
+	HelmMode        string
+	TillerNamespace string
+	TillerRole      string
+	K8sClient       kubernetes.Interface
+}
+
+// RoleStatus is the description of a role together with what currently exists of it
+type RoleStatus struct {
+	RoleDescription
+	BindingName   string
+	RoleExists    bool
+	BindingExists bool
+}
+
+func NewRegistry(helmMode, tillerNamespace, tillerRole string, k8sclient kubernetes.Interface) (registry *Registry) {
+	registry = &Registry{}
+	registry.HelmMode = helmMode
+	registry.TillerNamespace = tillerNamespace
+	registry.TillerRole = tillerRole
+	registry.K8sClient = k8sclient
+	return
+}
+
+func (registry *Registry) ProfileOptions(namespace, customRole, customClusterRole string) ProfileOptions {
+	return ProfileOptions{
+		Namespace:         namespace,
+		HelmMode:          registry.HelmMode,
+		TillerNamespace:   registry.TillerNamespace,
+		TillerRole:        registry.TillerRole,
+		CustomRole:        customRole,
+		CustomClusterRole: customClusterRole,
+	}
+}
+
+// AccountRoles returns the roles an account of namespace with profiles is bound to
+func (registry *Registry) AccountRoles(namespace string, profiles []string, customRole, customClusterRole string) ([]RbacInterface, error) {
+	return ResolveProfiles(profiles, registry.ProfileOptions(namespace, customRole, customClusterRole), registry.K8sClient)
+}
+
+// GrantRoles returns the roles an account is bound to inside namespace when granted profile there
+func (registry *Registry) GrantRoles(namespace, profile, customRole, customClusterRole string) ([]RbacInterface, error) {
+	return ResolveNamespaceProfile(profile, registry.ProfileOptions(namespace, customRole, customClusterRole), registry.K8sClient)
+}
+
+// candidateRoles returns every role the registry may have bound to the account inside namespace,
+// whatever the profiles or the helm mode were at that time. Cluster scoped roles and the tiller role
+// are included for the namespace of the account only.
+func (registry *Registry) candidateRoles(namespace, accountNamespace, accountName string) ([]RbacInterface, error) {
+	roles := []RbacInterface{
+		NewNamespaceViewerRole(namespace, registry.K8sClient),
+		NewNamespaceEditorRole(namespace, registry.K8sClient),
+		NewNamespaceAdminRole(namespace, registry.K8sClient),
+	}
+	if namespace == accountNamespace {
+		roles = append(roles,
+			NewHelmReleaseRole(namespace, registry.K8sClient),
+			NewClusterReadonlyRoleRole(namespace, ReadOnlyRole, registry.K8sClient),
+			NewTillerRole(registry.TillerNamespace, registry.TillerRole, registry.K8sClient))
+	}
+
+	// custom roles are only known from the bindings which refer to them
+	bindings, err := ListAccountRoleBindings(registry.K8sClient, accountNamespace, accountName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, each := range bindings {
+		if ProfileOfRoleRef(namespace, each.RoleRef) == ProfileCustom && !IsManagedRoleName(namespace, each.RoleRef.Name) {
+			roles = append(roles, NewCustomRole(namespace, each.RoleRef.Kind, each.RoleRef.Name, registry.K8sClient))
+		}
+	}
+	return roles, nil
+}
+
+// Provision makes roles the only roles the account is bound to inside its namespace, the tiller namespace
+// and the cluster scope, bound to subjects. The new bindings are created before stale ones are removed,
+// if any of them fails the bindings created by this call are rolled back, so the account keeps its previous permissions.
+func (registry *Registry) Provision(accountNamespace, accountName string, subjects []rbacV1.Subject, roles []RbacInterface) error {
+	candidates, err := registry.candidateRoles(accountNamespace, accountNamespace, accountName)
+	if err != nil {
+		return err
+	}
+	return registry.replaceBindings(accountNamespace, accountName, subjects, candidates, roles)
+}
+
+// Grant makes roles the only roles the account is bound to inside namespace, bindings elsewhere
+// are left alone. Passing no roles revokes the grant.
+func (registry *Registry) Grant(accountNamespace, accountName, namespace string, subjects []rbacV1.Subject, roles []RbacInterface) error {
+	candidates, err := registry.candidateRoles(namespace, accountNamespace, accountName)
+	if err != nil {
+		return err
+	}
+	return registry.replaceBindings(accountNamespace, accountName, subjects, candidates, roles)
+}
+
+// Deprovision removes every binding of the account, in its own namespace and in the namespaces it was granted
+func (registry *Registry) Deprovision(accountNamespace, accountName string) error {
+	namespaces := []string{accountNamespace}
+	bindings, err := ListAccountRoleBindings(registry.K8sClient, accountNamespace, accountName, metaV1.NamespaceAll)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{accountNamespace: true, registry.TillerNamespace: true}
+	for _, each := range bindings {
+		if !seen[each.Namespace] {
+			seen[each.Namespace] = true
+			namespaces = append(namespaces, each.Namespace)
+		}
+	}
+
+	for _, namespace := range namespaces {
+		candidates, err := registry.candidateRoles(namespace, accountNamespace, accountName)
+		if err != nil {
+			return err
+		}
+		for _, role := range candidates {
+			if err := role.DeleteRoleBinding(accountNamespace, accountName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Describe reports the roles of an account with profiles, and whether they and their bindings exist
+func (registry *Registry) Describe(accountNamespace, accountName string, roles []RbacInterface) ([]RoleStatus, error) {
+	var statuses []RoleStatus
+	for _, role := range roles {
+		status := RoleStatus{
+			RoleDescription: role.Describe(),
+			BindingName:     GenerateRoleBindingName(role.GetRoleName(), accountNamespace, accountName),
+		}
+		var err error
+		if status.RoleExists, err = role.RoleExists(); err != nil {
+			return nil, err
+		}
+		if status.BindingExists, err = role.RoleBindingExists(accountNamespace, accountName); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func bindingKey(role RbacInterface, accountNamespace, accountName string) string {
+	description := role.Describe()
+	return fmt.Sprintf("%s/%s/%s", description.BindingKind, description.BindingNamespace,
+		GenerateRoleBindingName(role.GetRoleName(), accountNamespace, accountName))
+}
+
+// bindingSnapshot is a binding of an account as it was before replaceBindings touched it,
+// both objects are nil when the binding did not exist
+type bindingSnapshot struct {
+	roleBinding        *rbacV1.RoleBinding
+	clusterRoleBinding *rbacV1.ClusterRoleBinding
+}
+
+func (registry *Registry) snapshotBinding(role RbacInterface, accountNamespace, accountName string) (bindingSnapshot, error) {
+	description := role.Describe()
+	name := GenerateRoleBindingName(role.GetRoleName(), accountNamespace, accountName)
+	snapshot := bindingSnapshot{}
+	if description.BindingKind == "ClusterRoleBinding" {
+		binding, err := registry.K8sClient.RbacV1().ClusterRoleBindings().Get(name, metaV1.GetOptions{})
+		if err == nil {
+			snapshot.clusterRoleBinding = binding
+		}
+		return snapshot, ignoreNotFound(err)
+	}
+	binding, err := registry.K8sClient.RbacV1().RoleBindings(description.BindingNamespace).Get(name, metaV1.GetOptions{})
+	if err == nil {
+		snapshot.roleBinding = binding
+	}
+	return snapshot, ignoreNotFound(err)
+}
+
+// restoreBinding brings a binding back to its snapshot, or removes it when it did not exist before
+func (registry *Registry) restoreBinding(role RbacInterface, accountNamespace, accountName string, snapshot bindingSnapshot) error {
+	switch {
+	case snapshot.roleBinding != nil:
+		binding := snapshot.roleBinding.DeepCopy()
+		binding.ResourceVersion = ""
+		_, err := reconcileRoleBinding(registry.K8sClient, binding)
+		return err
+	case snapshot.clusterRoleBinding != nil:
+		binding := snapshot.clusterRoleBinding.DeepCopy()
+		binding.ResourceVersion = ""
+		_, err := reconcileClusterRoleBinding(registry.K8sClient, binding)
+		return err
+	default:
+		return role.DeleteRoleBinding(accountNamespace, accountName)
+	}
+}
+
+func (registry *Registry) replaceBindings(accountNamespace, accountName string, subjects []rbacV1.Subject, candidates, roles []RbacInterface) error {
+	snapshots := map[string]bindingSnapshot{}
+	for _, role := range roles {
+		snapshot, err := registry.snapshotBinding(role, accountNamespace, accountName)
+		if err != nil {
+			return err
+		}
+		snapshots[bindingKey(role, accountNamespace, accountName)] = snapshot
+	}
+
+	for i, role := range roles {
+		err := role.CreateRole()
+		if err == nil {
+			// bindings which already exist get the current subjects of the account
+			_, err = role.ReconcileRoleBinding(accountNamespace, accountName, subjects)
+		}
+		if err != nil {
+			var rollbackErrors []string
+			for _, touched := range roles[:i+1] {
+				snapshot := snapshots[bindingKey(touched, accountNamespace, accountName)]
+				if rollbackErr := registry.restoreBinding(touched, accountNamespace, accountName, snapshot); rollbackErr != nil {
+					rollbackErrors = append(rollbackErrors, rollbackErr.Error())
+				}
+			}
+			if len(rollbackErrors) > 0 {
+				return fmt.Errorf("%s, rolling back failed as well: %s", err, strings.Join(rollbackErrors, "; "))
+			}
+			return err
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, role := range roles {
+		wanted[bindingKey(role, accountNamespace, accountName)] = true
+	}
+	for _, role := range candidates {
+		if wanted[bindingKey(role, accountNamespace, accountName)] {
+			continue
+		}
+		if err := role.DeleteRoleBinding(accountNamespace, accountName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package rbac
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sTesting "k8s.io/client-go/testing"
+)
+
+func TestProvisionRollsBack(t *testing.T) {
+	namespace, account := "clustar-a", "alice"
+	client := fake.NewSimpleClientset()
+	registry := NewRegistry(HelmModeDisabled, "", "", client)
+
+	viewer := NewNamespaceViewerRole(namespace, client)
+	editor := NewNamespaceEditorRole(namespace, client)
+	oldSubjects := []rbacV1.Subject{UserSubject("alice@example.com")}
+	if err := registry.Provision(namespace, account, oldSubjects, []RbacInterface{viewer}); err != nil {
+		t.Fatal(err)
+	}
+	viewerBinding := GenerateRoleBindingName(viewer.RoleName, namespace, account)
+	before, err := client.RbacV1().RoleBindings(namespace).Get(viewerBinding, metaV1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the binding of the editor is the second one created, after the viewer one got the new subjects
+	editorBinding := GenerateRoleBindingName(editor.RoleName, namespace, account)
+	client.PrependReactor("create", "rolebindings", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		if action.(k8sTesting.CreateAction).GetObject().(*rbacV1.RoleBinding).Name == editorBinding {
+			return true, nil, errors.New("refused")
+		}
+		return false, nil, nil
+	})
+	newSubjects := []rbacV1.Subject{GroupSubject("team")}
+	if err := registry.Provision(namespace, account, newSubjects, []RbacInterface{viewer, editor}); err == nil {
+		t.Fatal("Provision succeeded although the editor binding was refused")
+	}
+
+	after, err := client.RbacV1().RoleBindings(namespace).Get(viewerBinding, metaV1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after.Subjects, before.Subjects) {
+		t.Errorf("subjects of %s = %v, want them restored to %v", viewerBinding, after.Subjects, before.Subjects)
+	}
+	if _, err := client.RbacV1().RoleBindings(namespace).Get(editorBinding, metaV1.GetOptions{}); !k8sError.IsNotFound(err) {
+		t.Errorf("binding %s is left after the rollback: %v", editorBinding, err)
+	}
+}
@@ -0,0 +1,155 @@
+package rbac
+
+import (
+	"sort"
+	"strings"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+)
+
+// expandRules flattens rules into single permissions, so that two lists of rules
+// can be compared no matter how the permissions are grouped into rules
+func expandRules(rules []rbacV1.PolicyRule) map[string]bool {
+	permissions := map[string]bool{}
+	for _, rule := range rules {
+		for _, verb := range rule.Verbs {
+			for _, url := range rule.NonResourceURLs {
+				permissions[strings.Join([]string{"url", url, verb}, "|")] = true
+			}
+			names := rule.ResourceNames
+			if len(names) == 0 {
+				names = []string{""}
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					for _, name := range names {
+						permissions[strings.Join([]string{group, resource, name, verb}, "|")] = true
+					}
+				}
+			}
+		}
+	}
+	return permissions
+}
+
+// DiffRules returns the permissions which desired has and current has not, and the other way round
+func DiffRules(current, desired []rbacV1.PolicyRule) (added, removed []string) {
+	currentPermissions := expandRules(current)
+	desiredPermissions := expandRules(desired)
+	for permission := range desiredPermissions {
+		if !currentPermissions[permission] {
+			added = append(added, permission)
+		}
+	}
+	for permission := range currentPermissions {
+		if !desiredPermissions[permission] {
+			removed = append(removed, permission)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
+
+// RulesEqual tells if two lists of rules grant exactly the same permissions
+func RulesEqual(current, desired []rbacV1.PolicyRule) bool {
+	added, removed := DiffRules(current, desired)
+	return len(added) == 0 && len(removed) == 0
+}
+
+func subjectsEqual(current, desired []rbacV1.Subject) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+	seen := map[rbacV1.Subject]bool{}
+	for _, subject := range current {
+		seen[normalizeSubject(subject)] = true
+	}
+	for _, subject := range desired {
+		if !seen[normalizeSubject(subject)] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeSubject(subject rbacV1.Subject) rbacV1.Subject {
+	if subject.Kind != rbacV1.ServiceAccountKind && subject.APIGroup == "" {
+		subject.APIGroup = rbacV1.GroupName
+	}
+	if subject.Kind == rbacV1.ServiceAccountKind {
+		subject.APIGroup = ""
+	}
+	return subject
+}
+
+func roleRefEqual(current, desired rbacV1.RoleRef) bool {
+	return current.Kind == desired.Kind && current.Name == desired.Name
+}
+
+// UncoveredPermissions returns the permissions of rules which none of the ceiling rules grants,
+// a wildcard in rules is only covered by a wildcard in ceiling
+func UncoveredPermissions(ceiling []rbacV1.PolicyRule, rules []rbacV1.PolicyRule) []string {
+	var uncovered []string
+	for _, rule := range rules {
+		for _, verb := range rule.Verbs {
+			for _, url := range rule.NonResourceURLs {
+				if !coversNonResource(ceiling, url, verb) {
+					uncovered = append(uncovered, strings.Join([]string{"url", url, verb}, "|"))
+				}
+			}
+			names := rule.ResourceNames
+			if len(names) == 0 {
+				names = []string{""}
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					for _, name := range names {
+						if !coversResource(ceiling, group, resource, name, verb) {
+							uncovered = append(uncovered, strings.Join([]string{group, resource, name, verb}, "|"))
+						}
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(uncovered)
+	return uncovered
+}
+
+func coversResource(ceiling []rbacV1.PolicyRule, group, resource, name, verb string) bool {
+	for _, rule := range ceiling {
+		if !matches(rule.APIGroups, group) || !matches(rule.Resources, resource) || !matches(rule.Verbs, verb) {
+			continue
+		}
+		// a ceiling restricted to some names does not cover all names
+		if len(rule.ResourceNames) == 0 || (name != "" && matches(rule.ResourceNames, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func coversNonResource(ceiling []rbacV1.PolicyRule, url, verb string) bool {
+	for _, rule := range ceiling {
+		if !matches(rule.Verbs, verb) {
+			continue
+		}
+		for _, each := range rule.NonResourceURLs {
+			if each == "*" || each == url ||
+				(strings.HasSuffix(each, "*") && strings.HasPrefix(url, strings.TrimSuffix(each, "*"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matches(values []string, value string) bool {
+	for _, each := range values {
+		if each == rbacV1.ResourceAll || each == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	rbacV1 "k8s.io/api/rbac/v1"
+)
+
+func TestDiffRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []rbacV1.PolicyRule
+		desired []rbacV1.PolicyRule
+		added   []string
+		removed []string
+	}{
+		{
+			name:    "same rules",
+			current: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}},
+			desired: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}},
+		},
+		{
+			name: "same permissions grouped differently",
+			current: []rbacV1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+				{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+			},
+			desired: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "get"}}},
+		},
+		{
+			name:    "verb added",
+			current: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}},
+			desired: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "delete"}}},
+			added:   []string{"|pods||delete"},
+		},
+		{
+			name:    "resource removed",
+			current: []rbacV1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets"}, Verbs: []string{"get"}}},
+			desired: []rbacV1.PolicyRule{{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}},
+			removed: []string{"apps|statefulsets||get"},
+		},
+		{
+			name:    "resource name and non resource url",
+			current: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"a"}, Verbs: []string{"get"}}},
+			desired: []rbacV1.PolicyRule{{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}}},
+			added:   []string{"url|/healthz|get"},
+			removed: []string{"|configmaps|a|get"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			added, removed := DiffRules(test.current, test.desired)
+			if !reflect.DeepEqual(added, test.added) {
+				t.Errorf("added = %v, want %v", added, test.added)
+			}
+			if !reflect.DeepEqual(removed, test.removed) {
+				t.Errorf("removed = %v, want %v", removed, test.removed)
+			}
+			if equal := RulesEqual(test.current, test.desired); equal != (len(test.added) == 0 && len(test.removed) == 0) {
+				t.Errorf("RulesEqual = %v", equal)
+			}
+		})
+	}
+}
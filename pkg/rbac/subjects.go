@@ -0,0 +1,111 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	// UsersAnnotation and GroupsAnnotation record the OIDC users and groups bound together with an account,
+	// BindServiceAccountAnnotation set to "false" keeps the ServiceAccount itself out of the bindings
+	UsersAnnotation              = "clustar.ai/users"
+	GroupsAnnotation             = "clustar.ai/groups"
+	BindServiceAccountAnnotation = "clustar.ai/bind-serviceaccount"
+
+	// names reserved by kubernetes, binding them would grant access to far more than one person
+	systemSubjectPrefix = "system:"
+)
+
+func ServiceAccountSubject(accountNamespace, accountName string) rbacV1.Subject {
+	return rbacV1.Subject{
+		Kind:      rbacV1.ServiceAccountKind,
+		Name:      accountName,
+		Namespace: accountNamespace,
+	}
+}
+
+func UserSubject(name string) rbacV1.Subject {
+	return rbacV1.Subject{
+		Kind:     rbacV1.UserKind,
+		APIGroup: rbacV1.GroupName,
+		Name:     name,
+	}
+}
+
+func GroupSubject(name string) rbacV1.Subject {
+	return rbacV1.Subject{
+		Kind:     rbacV1.GroupKind,
+		APIGroup: rbacV1.GroupName,
+		Name:     name,
+	}
+}
+
+// AccountSubjects returns the subjects the bindings of an account refer to
+func AccountSubjects(accountNamespace, accountName string, bindServiceAccount bool, users, groups []string) []rbacV1.Subject {
+	var subjects []rbacV1.Subject
+	if bindServiceAccount {
+		subjects = append(subjects, ServiceAccountSubject(accountNamespace, accountName))
+	}
+	for _, user := range users {
+		subjects = append(subjects, UserSubject(user))
+	}
+	for _, group := range groups {
+		subjects = append(subjects, GroupSubject(group))
+	}
+	return subjects
+}
+
+// SubjectsOfServiceAccount reads the subjects recorded on an account,
+// accounts provisioned before users and groups were recorded only bind their ServiceAccount
+func SubjectsOfServiceAccount(serviceAccount *coreV1.ServiceAccount) []rbacV1.Subject {
+	users, groups := UsersAndGroupsOfServiceAccount(serviceAccount)
+	return AccountSubjects(serviceAccount.Namespace, serviceAccount.Name, BindsServiceAccount(serviceAccount), users, groups)
+}
+
+func UsersAndGroupsOfServiceAccount(serviceAccount *coreV1.ServiceAccount) (users, groups []string) {
+	return splitAnnotation(serviceAccount.Annotations[UsersAnnotation]),
+		splitAnnotation(serviceAccount.Annotations[GroupsAnnotation])
+}
+
+// BindsServiceAccount tells if the ServiceAccount itself is a subject of the bindings of its account
+func BindsServiceAccount(serviceAccount *coreV1.ServiceAccount) bool {
+	return serviceAccount.Annotations[BindServiceAccountAnnotation] != "false"
+}
+
+// ValidateSubjectNames rejects users and groups which cannot be recorded on an account,
+// or which belong to kubernetes itself
+func ValidateSubjectNames(users, groups []string) error {
+	check := func(kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s name must not be empty", kind)
+		}
+		if strings.Contains(name, ",") {
+			return fmt.Errorf("%s name must not contain a comma: %s", kind, name)
+		}
+		if strings.HasPrefix(name, systemSubjectPrefix) {
+			return fmt.Errorf("%s %s is reserved by kubernetes", kind, name)
+		}
+		return nil
+	}
+	for _, user := range users {
+		if err := check(rbacV1.UserKind, user); err != nil {
+			return err
+		}
+	}
+	for _, group := range groups {
+		if err := check(rbacV1.GroupKind, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitAnnotation(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -1,6 +1,7 @@
 package rbac
 
 import (
+	"github.com/golang/glog"
 	rbacV1 "k8s.io/api/rbac/v1"
 	k8sError "k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -9,6 +10,8 @@ import (
 
 type TillerRole struct {
 	BaseRole
+	// an optional tiller role which does not exist is skipped instead of failing the provisioning
+	Optional  bool
 	K8sClient kubernetes.Interface
 }
 
@@ -23,40 +26,64 @@ func NewTillerRole(namespace, rolename string, k8sclient kubernetes.Interface) (
 // tiller 的 role 应该在安装helm的时候创建好，所以这里只是检查是否存在，不执行创建了
 func (role *TillerRole) CreateRole() error {
 	_, err := role.K8sClient.RbacV1().Roles(role.Namespace).Get(role.RoleName, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) && role.Optional {
+		glog.Warningf("tiller role %s/%s does not exist, skip binding it", role.Namespace, role.RoleName)
+		return nil
+	}
 	return err
 }
 
-func (role *TillerRole) CreateRoleBinding(accountNamespace, accountName string) error {
-	bindingName := GenerateRoleBindingName(role.RoleName, accountNamespace, accountName)
-	_, err := role.K8sClient.RbacV1().RoleBindings(role.Namespace).Get(bindingName, metaV1.GetOptions{})
-	if err != nil {
-		switch t := err.(type) {
-		case *k8sError.StatusError:
-			if t.Status().Reason == metaV1.StatusReasonNotFound {
-				rolebindingtmp := &rbacV1.RoleBinding{}
-				rolebindingtmp.APIVersion = "v1"
-				rolebindingtmp.Kind = "RoleBinding"
-				rolebindingtmp.Name = bindingName
-				rolebindingtmp.Namespace = role.Namespace
-				rolebindingtmp.Subjects = append(rolebindingtmp.Subjects, rbacV1.Subject{
-					Kind:      "ServiceAccount",
-					Name:      accountName,
-					Namespace: accountNamespace,
-				})
-				rolebindingtmp.RoleRef.Kind = "Role"
-				rolebindingtmp.RoleRef.Name = role.RoleName
-
-				_, err = role.K8sClient.RbacV1().RoleBindings(role.Namespace).Create(rolebindingtmp)
-				if err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		default:
-			return err
-		}
+func (role *TillerRole) CreateRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) error {
+	if missing, err := role.missing(); missing || err != nil {
+		return err
+	}
+	return createRoleBindingIfNotExists(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+// tiller 的 role 不归这里管理，所以不需要调整
+func (role *TillerRole) ReconcileRole() (bool, error) {
+	return false, nil
+}
+
+func (role *TillerRole) ReconcileRoleBinding(accountNamespace, accountName string, subjects []rbacV1.Subject) (bool, error) {
+	if missing, err := role.missing(); missing || err != nil {
+		return false, err
+	}
+	return reconcileRoleBinding(role.K8sClient,
+		newAccountRoleBinding(role.Namespace, "Role", role.RoleName, accountNamespace, accountName, subjects))
+}
+
+func (role *TillerRole) DeleteRoleBinding(accountNamespace, accountName string) error {
+	return deleteRoleBinding(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *TillerRole) RoleExists() (bool, error) {
+	return roleExists(role.K8sClient, role.Namespace, role.RoleName)
+}
+
+func (role *TillerRole) RoleBindingExists(accountNamespace, accountName string) (bool, error) {
+	return roleBindingExists(role.K8sClient, role.Namespace, GenerateRoleBindingName(role.RoleName, accountNamespace, accountName))
+}
+
+func (role *TillerRole) Describe() RoleDescription {
+	return namespacedRoleDescription(role.Namespace, role.RoleName, false)
+}
+
+// missing tells if an optional tiller role does not exist
+func (role *TillerRole) missing() (bool, error) {
+	if !role.Optional {
+		return false, nil
 	}
+	_, err := role.K8sClient.RbacV1().Roles(role.Namespace).Get(role.RoleName, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
 
-	return nil
+// IsTillerBinding tells if a binding inside the tiller namespace was created for the tiller role
+func IsTillerBinding(tillerRole string, binding rbacV1.RoleBinding) bool {
+	_, _, ok := ParseRoleBindingName(binding.Name)
+	return ok && binding.RoleRef.Kind == "Role" && binding.RoleRef.Name == tillerRole
 }
@@ -0,0 +1,103 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type storageBudgetEntity struct {
+	Namespace string `json:"namespace" description:"name of the namespace"`
+	Budget    string `json:"budget,omitempty" description:"storage the namespace may claim, unlimited when empty"`
+	Default   bool   `json:"default" description:"whether the namespace has the default budget"`
+	Used      string `json:"used" description:"storage requested by the claims of the namespace"`
+	Remaining string `json:"remaining,omitempty" description:"storage which may still be claimed"`
+}
+
+type storageBudgetAction struct {
+	Budget string `json:"budget" description:"storage the namespace may claim, empty for the default budget"`
+}
+
+func (nsr NameSpacesResource) newStorageBudgetEntity(nameOfSpace string) (*storageBudgetEntity, error) {
+	namespace, err := nsr.k8sClient.CoreV1().Namespaces().Get(nameOfSpace, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	budget, err := nsr.budgets.BudgetOfNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	used, err := nsr.budgets.Used(nameOfSpace)
+	if err != nil {
+		return nil, err
+	}
+	_, own := namespace.Annotations[storage.BudgetAnnotation]
+	entity := &storageBudgetEntity{
+		Namespace: nameOfSpace,
+		Default:   !own,
+		Used:      used.String(),
+	}
+	if budget != nil {
+		remaining := budget.DeepCopy()
+		remaining.Sub(used)
+		if remaining.Sign() < 0 {
+			remaining = resource.Quantity{}
+		}
+		entity.Budget = budget.String()
+		entity.Remaining = remaining.String()
+	}
+	return entity, nil
+}
+
+// GET http://localhost:8080/namespaces/clustar-{ns}/storage-budget
+//
+func (nsr NameSpacesResource) findStorageBudget(request *restful.Request, response *restful.Response) {
+	entity, err := nsr.newStorageBudgetEntity(request.PathParameter("namespace"))
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(entity)
+}
+
+// PUT http://localhost:8080/namespaces/clustar-{ns}/storage-budget
+//
+func (nsr NameSpacesResource) updateStorageBudget(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	if !strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", nameOfSpace)))
+		return
+	}
+	action := &storageBudgetAction{}
+	if err := request.ReadEntity(action); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	var budget *resource.Quantity
+	if action.Budget != "" {
+		quantity, err := resource.ParseQuantity(action.Budget)
+		if err != nil || quantity.Sign() < 0 {
+			response.WriteError(http.StatusBadRequest, errors.New(fmt.Sprintf("invalid storage budget: %s", action.Budget)))
+			return
+		}
+		budget = &quantity
+	}
+
+	if err := nsr.budgets.SetBudget(nameOfSpace, budget); err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	entity, err := nsr.newStorageBudgetEntity(nameOfSpace)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(entity)
+}
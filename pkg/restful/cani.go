@@ -0,0 +1,95 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	authorizationV1 "k8s.io/api/authorization/v1"
+)
+
+// the username and groups the api server authenticates a service account token as
+const (
+	serviceAccountUsernamePattern = "system:serviceaccount:%s:%s"
+	serviceAccountsGroup          = "system:serviceaccounts"
+	authenticatedGroup            = "system:authenticated"
+)
+
+type canIAction struct {
+	Verb           string `json:"verb" description:"verb to check, like get, list, create or delete"`
+	Group          string `json:"group,omitempty" description:"api group of the resource, empty for the core group"`
+	Resource       string `json:"resource,omitempty" description:"resource to check"`
+	Subresource    string `json:"subresource,omitempty" description:"subresource to check, like log or exec"`
+	Namespace      string `json:"namespace,omitempty" description:"namespace of the resource, empty for cluster scoped resources or all namespaces"`
+	Name           string `json:"name,omitempty" description:"name of the resource, empty for all of them"`
+	NonResourceURL string `json:"nonResourceURL,omitempty" description:"non resource url to check instead of a resource, like /healthz"`
+}
+
+type canIEntity struct {
+	canIAction      `json:",inline"`
+	Allowed         bool   `json:"allowed" description:"whether the action is allowed"`
+	Denied          bool   `json:"denied,omitempty" description:"whether the action is explicitly denied"`
+	Reason          string `json:"reason,omitempty" description:"why the action is allowed or denied"`
+	EvaluationError string `json:"evaluationError,omitempty" description:"error met while checking the action"`
+}
+
+// POST http://localhost:8080/serviceAccount/clustar-{ns}/default/can-i
+func (sar ServiceAccountResource) canI(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAccount := request.PathParameter("serviceAccount")
+
+	var actions []canIAction
+	if err := request.ReadEntity(&actions); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	for _, action := range actions {
+		if action.Verb == "" || (action.Resource == "") == (action.NonResourceURL == "") {
+			response.WriteError(http.StatusBadRequest,
+				errors.New(fmt.Sprintf("every action needs a verb and either a resource or a nonResourceURL: %+v", action)))
+			return
+		}
+	}
+
+	results := []canIEntity{}
+	for _, action := range actions {
+		review := &authorizationV1.SubjectAccessReview{
+			Spec: authorizationV1.SubjectAccessReviewSpec{
+				User: fmt.Sprintf(serviceAccountUsernamePattern, nameOfSpace, nameOfAccount),
+				Groups: []string{serviceAccountsGroup,
+					fmt.Sprintf("%s:%s", serviceAccountsGroup, nameOfSpace),
+					authenticatedGroup},
+			},
+		}
+		if action.NonResourceURL != "" {
+			review.Spec.NonResourceAttributes = &authorizationV1.NonResourceAttributes{
+				Path: action.NonResourceURL,
+				Verb: action.Verb,
+			}
+		} else {
+			review.Spec.ResourceAttributes = &authorizationV1.ResourceAttributes{
+				Namespace:   action.Namespace,
+				Verb:        action.Verb,
+				Group:       action.Group,
+				Resource:    action.Resource,
+				Subresource: action.Subresource,
+				Name:        action.Name,
+			}
+		}
+
+		review, err := sar.k8sClient.AuthorizationV1().SubjectAccessReviews().Create(review)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		results = append(results, canIEntity{
+			canIAction:      action,
+			Allowed:         review.Status.Allowed,
+			Denied:          review.Status.Denied,
+			Reason:          review.Status.Reason,
+			EvaluationError: review.Status.EvaluationError,
+		})
+	}
+	response.WriteEntity(results)
+}
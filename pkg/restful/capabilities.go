@@ -0,0 +1,63 @@
+package restful
+
+import (
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/capability"
+)
+
+type CapabilityResource struct {
+	detector *capability.Detector
+}
+
+func createCapabilityResource(detector *capability.Detector) (resource *CapabilityResource) {
+	resource = &CapabilityResource{
+		detector: detector,
+	}
+	return
+}
+
+type capabilitiesEntity struct {
+	Capabilities    []capability.Status `json:"capabilities" description:"optional integrations of the cluster, empty until detected once, all of them count as available meanwhile"`
+	RefreshedAt     string              `json:"refreshedAt,omitempty" description:"when the integrations were last detected"`
+	RefreshInterval string              `json:"refreshInterval" description:"how often the integrations are detected"`
+	Error           string              `json:"error,omitempty" description:"error met by the last detection, the previous answer is kept"`
+}
+
+func (cr CapabilityResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/capabilities").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"capabilities"}
+
+	ws.Route(ws.GET("/").To(cr.findCapabilities).
+		// docs
+		Doc("get the optional integrations detected in the cluster, provisioning steps and role rules of missing ones are disabled").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(capabilitiesEntity{}). // on the response
+		Returns(200, "OK", nil))
+
+	return ws
+}
+
+// GET http://localhost:8080/capabilities
+//
+func (cr CapabilityResource) findCapabilities(request *restful.Request, response *restful.Response) {
+	statuses, refreshedAt, lastError := cr.detector.Statuses()
+	result := capabilitiesEntity{
+		Capabilities:    statuses,
+		RefreshInterval: cr.detector.RefreshInterval.String(),
+		Error:           lastError,
+	}
+	if result.Capabilities == nil {
+		result.Capabilities = []capability.Status{}
+	}
+	if !refreshedAt.IsZero() {
+		result.RefreshedAt = refreshedAt.Format(time.RFC3339)
+	}
+	response.WriteEntity(result)
+}
@@ -1,14 +1,23 @@
 package restful
 
 import (
+	"errors"
+	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"net/http"
 )
 
+type readonlyPieceAction struct {
+	Name      string   `json:"name" description:"name of the piece, the cluster role is named cluster-readonly:{name}"`
+	APIGroups []string `json:"apiGroups" description:"api groups of the readable resources"`
+	Resources []string `json:"resources" description:"readable resources"`
+}
+
 type ClusterRoleResource struct {
 	k8sClient kubernetes.Interface
 }
@@ -45,6 +54,32 @@ func (crr ClusterRoleResource) WebService() *restful.WebService {
 		Returns(200, "OK", nil).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(ws.GET("/readonly/pieces").To(crr.findAllReadonlyPieces).
+		// docs
+		Doc("find all cluster roles aggregated into the cluster readonly role").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]rbacv1.ClusterRole{}). // on the response
+		Returns(200, "OK", nil))
+
+	ws.Route(ws.POST("/readonly/pieces").To(crr.createReadonlyPiece).
+		// docs
+		Doc("make more api groups and resources readable by every account through the cluster readonly role").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(readonlyPieceAction{}).
+		Writes(rbacv1.ClusterRole{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(409, "Conflict", nil))
+
+	ws.Route(ws.DELETE("/readonly/pieces/{piece}").To(crr.removeReadonlyPiece).
+		// docs
+		Doc("remove a registered piece of the cluster readonly role").
+		Param(ws.PathParameter("piece", "name of the piece").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes("").
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
 	return ws
 }
 
@@ -75,3 +110,54 @@ func (crr ClusterRoleResource) getClusterRole(request *restful.Request, response
 	}
 	response.WriteEntity(role)
 }
+
+// GET http://localhost:8080/clusterroles/readonly/pieces
+//
+func (crr ClusterRoleResource) findAllReadonlyPieces(request *restful.Request, response *restful.Response) {
+	pieces, err := rbac.ListReadonlyPieces(crr.k8sClient)
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	response.WriteEntity(pieces)
+}
+
+// POST http://localhost:8080/clusterroles/readonly/pieces
+//
+func (crr ClusterRoleResource) createReadonlyPiece(request *restful.Request, response *restful.Response) {
+	action := &readonlyPieceAction{}
+	if err := request.ReadEntity(action); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	piece := rbac.ReadonlyPiece{Name: action.Name, APIGroups: action.APIGroups, Resources: action.Resources}
+	if err := piece.Validate(); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	role, err := rbac.CreateReadonlyPiece(crr.k8sClient, piece)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(role)
+}
+
+// DELETE http://localhost:8080/clusterroles/readonly/pieces/{piece}
+//
+func (crr ClusterRoleResource) removeReadonlyPiece(request *restful.Request, response *restful.Response) {
+	nameOfPiece := request.PathParameter("piece")
+	if rbac.IsDefaultReadonlyPiece(nameOfPiece) {
+		response.WriteError(http.StatusBadRequest,
+			errors.New(fmt.Sprintf("piece: %s is a default piece, cannot remove through clusterroles!", nameOfPiece)))
+		return
+	}
+
+	err := rbac.DeleteReadonlyPiece(crr.k8sClient, nameOfPiece)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
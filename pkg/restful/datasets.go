@@ -0,0 +1,226 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+	"k8s.io/client-go/kubernetes"
+)
+
+type DatasetResource struct {
+	k8sClient                kubernetes.Interface
+	selfDefineResourcePrefix string
+	datasets                 *storage.Datasets
+}
+
+func createDatasetResource(k8sClient kubernetes.Interface, prefix string, datasets *storage.Datasets) (resource *DatasetResource) {
+	resource = &DatasetResource{
+		k8sClient:                k8sClient,
+		selfDefineResourcePrefix: prefix,
+		datasets:                 datasets,
+	}
+	return
+}
+
+type datasetEntity struct {
+	storage.Dataset
+	Tenants []storage.Attachment `json:"tenants" description:"namespaces the dataset has been attached to"`
+}
+
+func (dr DatasetResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/datasets").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"datasets"}
+
+	ws.Route(ws.GET("/").To(dr.findAllDatasets).
+		// docs
+		Doc("get all datasets together with the tenants they are attached to").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]datasetEntity{}).
+		Returns(200, "OK", []datasetEntity{}))
+
+	ws.Route(ws.POST("/").To(dr.registerDataset).
+		// docs
+		Doc("register a dataset which can be attached read only to many tenants").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(storage.Dataset{}).
+		Returns(200, "OK", Result{}).
+		Returns(400, "Invalid dataset", nil).
+		Returns(409, "Already registered", nil))
+
+	ws.Route(ws.GET("/{dataset}").To(dr.findDataset).
+		// docs
+		Doc("get a dataset together with the tenants it is attached to").
+		Param(ws.PathParameter("dataset", "name of the dataset").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(datasetEntity{}).
+		Returns(200, "OK", datasetEntity{}).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.DELETE("/{dataset}").To(dr.unregisterDataset).
+		// docs
+		Doc("unregister a dataset which is not attached to any tenant, the data itself is kept").
+		Param(ws.PathParameter("dataset", "name of the dataset").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", Result{}).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Still attached", nil))
+
+	ws.Route(ws.POST("/{dataset}/tenants/{namespace}").To(dr.attachDataset).
+		// docs
+		Doc("attach a dataset to a namespace through a pv and pvc of its own, mounted read only, the claim only takes a nominal size from the storage budget").
+		Param(ws.PathParameter("dataset", "name of the dataset").DataType("string")).
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(storage.Attachment{}).
+		Returns(200, "OK", storage.Attachment{}).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.DELETE("/{dataset}/tenants/{namespace}").To(dr.detachDataset).
+		// docs
+		Doc("detach a dataset from a namespace, refused while pods mount it").
+		Param(ws.PathParameter("dataset", "name of the dataset").DataType("string")).
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", Result{}).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Mounted by running pods", volumeInUseEntity{}))
+
+	return ws
+}
+
+// GET http://localhost:8080/datasets
+//
+func (dr DatasetResource) findAllDatasets(request *restful.Request, response *restful.Response) {
+	datasets, err := dr.datasets.List()
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	list := []datasetEntity{}
+	for _, dataset := range datasets {
+		entity, err := dr.newDatasetEntity(dataset)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		list = append(list, entity)
+	}
+	response.WriteEntity(list)
+}
+
+// POST http://localhost:8080/datasets
+//
+func (dr DatasetResource) registerDataset(request *restful.Request, response *restful.Response) {
+	dataset := &storage.Dataset{}
+	if err := request.ReadEntity(dataset); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if err := dataset.Validate(); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if err := dr.datasets.Register(dataset); err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
+
+// GET http://localhost:8080/datasets/{dataset}
+//
+func (dr DatasetResource) findDataset(request *restful.Request, response *restful.Response) {
+	dataset, err := dr.datasets.Get(request.PathParameter("dataset"))
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	entity, err := dr.newDatasetEntity(*dataset)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(entity)
+}
+
+// DELETE http://localhost:8080/datasets/{dataset}
+//
+func (dr DatasetResource) unregisterDataset(request *restful.Request, response *restful.Response) {
+	err := dr.datasets.Unregister(request.PathParameter("dataset"))
+	if err != nil {
+		response.WriteError(statusOfStorageError(err), err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
+
+// POST http://localhost:8080/datasets/{dataset}/tenants/clustar-{ns}
+//
+func (dr DatasetResource) attachDataset(request *restful.Request, response *restful.Response) {
+	nameOfDataset := request.PathParameter("dataset")
+	nameOfSpace := request.PathParameter("namespace")
+	if !strings.HasPrefix(nameOfSpace, dr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", nameOfSpace)))
+		return
+	}
+
+	attachment, err := dr.datasets.Attach(nameOfDataset, nameOfSpace)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(attachment)
+}
+
+// DELETE http://localhost:8080/datasets/{dataset}/tenants/clustar-{ns}
+//
+func (dr DatasetResource) detachDataset(request *restful.Request, response *restful.Response) {
+	nameOfDataset := request.PathParameter("dataset")
+	nameOfSpace := request.PathParameter("namespace")
+	if !strings.HasPrefix(nameOfSpace, dr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot remove through service!", nameOfSpace)))
+		return
+	}
+
+	nameOfClaim := storage.ClaimNameOfDataset(nameOfDataset)
+	pods, err := podsMountingClaim(dr.k8sClient, nameOfSpace, nameOfClaim)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if len(pods) > 0 {
+		response.WriteHeaderAndEntity(http.StatusConflict, volumeInUseEntity{
+			Message: fmt.Sprintf("dataset %s is mounted by running pods of %s", nameOfDataset, nameOfSpace),
+			Pods:    pods,
+		})
+		return
+	}
+
+	if err := dr.datasets.Detach(nameOfDataset, nameOfSpace); err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
+
+func (dr DatasetResource) newDatasetEntity(dataset storage.Dataset) (datasetEntity, error) {
+	attachments, err := dr.datasets.Attachments(dataset.Name)
+	if err != nil {
+		return datasetEntity{}, err
+	}
+	if attachments == nil {
+		attachments = []storage.Attachment{}
+	}
+	return datasetEntity{Dataset: dataset, Tenants: attachments}, nil
+}
@@ -0,0 +1,161 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type grantAction struct {
+	Profile     string `json:"profile" description:"access profile inside the granted namespace: viewer, editor, admin or custom, default is admin"`
+	Role        string `json:"role,omitempty" description:"existing role in the granted namespace bind by the custom profile"`
+	ClusterRole string `json:"clusterrole,omitempty" description:"existing cluster role bind inside the granted namespace by the custom profile"`
+}
+
+type grantEntity struct {
+	Namespace string   `json:"namespace" description:"name of the granted namespace"`
+	Profile   string   `json:"profile" description:"access profile inside the granted namespace"`
+	Bindings  []string `json:"bindings" description:"role bindings which implement the grant"`
+}
+
+// listGrants collects the role bindings of the account outside its own namespace and the tiller namespace
+func (kcr KubeConfigResource) listGrants(nameOfSpace, nameOfAccount string) ([]grantEntity, error) {
+	bindings, err := rbac.ListAccountRoleBindings(kcr.k8sClient, nameOfSpace, nameOfAccount, metaV1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := map[string]*grantEntity{}
+	for _, each := range bindings {
+		if each.Namespace == nameOfSpace || each.Namespace == kcr.registry.TillerNamespace {
+			continue
+		}
+		grant, ok := grants[each.Namespace]
+		if !ok {
+			grant = &grantEntity{Namespace: each.Namespace, Profile: rbac.ProfileOfRoleRef(each.Namespace, each.RoleRef)}
+			grants[each.Namespace] = grant
+		}
+		grant.Bindings = append(grant.Bindings, each.Name)
+	}
+
+	list := []grantEntity{}
+	for _, grant := range grants {
+		list = append(list, *grant)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Namespace < list[j].Namespace })
+	return list, nil
+}
+
+// GET http://localhost:8080/kubeconfig/clustar-{ns}/default/grants
+//
+func (kcr KubeConfigResource) findAllGrants(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAccount := request.PathParameter("serviceAccount")
+
+	grants, err := kcr.listGrants(nameOfSpace, nameOfAccount)
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	response.WriteEntity(grants)
+}
+
+// PUT http://localhost:8080/kubeconfig/clustar-{ns}/default/grants/clustar-{other}
+//
+func (kcr KubeConfigResource) grantNamespace(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAccount := request.PathParameter("serviceAccount")
+	nameOfGrant := request.PathParameter("grantNamespace")
+
+	action := &grantAction{}
+	if err := request.ReadEntity(action); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if action.Profile == "" {
+		action.Profile = rbac.DefaultProfile
+	}
+
+	serviceAccount, statenum, err := kcr.checkGrantNamespace(nameOfSpace, nameOfAccount, nameOfGrant)
+	if err != nil {
+		response.WriteError(statenum, err)
+		return
+	}
+	if !rbac.IsValidProfile(action.Profile) {
+		response.WriteError(http.StatusBadRequest, errors.New(fmt.Sprintf("unknown profile: %s", action.Profile)))
+		return
+	}
+
+	roles, err := kcr.registry.GrantRoles(nameOfGrant, action.Profile, action.Role, action.ClusterRole)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if err := kcr.checkCustomRoles(roles); err != nil {
+		response.WriteError(statusOfCheckError(err), err)
+		return
+	}
+
+	// granting again with another profile replaces the previous one
+	err = kcr.registry.Grant(nameOfSpace, nameOfAccount, nameOfGrant, rbac.SubjectsOfServiceAccount(serviceAccount), roles)
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+
+	grant := grantEntity{Namespace: nameOfGrant, Profile: action.Profile}
+	for _, role := range roles {
+		grant.Bindings = append(grant.Bindings, rbac.GenerateRoleBindingName(role.GetRoleName(), nameOfSpace, nameOfAccount))
+	}
+	response.WriteEntity(grant)
+}
+
+// DELETE http://localhost:8080/kubeconfig/clustar-{ns}/default/grants/clustar-{other}
+//
+func (kcr KubeConfigResource) revokeNamespace(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAccount := request.PathParameter("serviceAccount")
+	nameOfGrant := request.PathParameter("grantNamespace")
+
+	if !strings.HasPrefix(nameOfGrant, kcr.selfDefineResourcePrefix) || nameOfGrant == nameOfSpace {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s cannot be revoked through service!", nameOfGrant)))
+		return
+	}
+
+	err := kcr.registry.Grant(nameOfSpace, nameOfAccount, nameOfGrant, nil, nil)
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
+
+// checkGrantNamespace returns the ServiceAccount which records the subjects of the account
+func (kcr KubeConfigResource) checkGrantNamespace(nameOfSpace, nameOfAccount, nameOfGrant string) (*coreV1.ServiceAccount, int, error) {
+	if !strings.HasPrefix(nameOfGrant, kcr.selfDefineResourcePrefix) {
+		return nil, http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot grant through service!", nameOfGrant))
+	}
+	if nameOfGrant == nameOfSpace {
+		return nil, http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is the namespace of the account, change its profiles instead!", nameOfGrant))
+	}
+
+	serviceAccount, err := kcr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Get(nameOfAccount, metaV1.GetOptions{})
+	if err != nil {
+		return nil, statusOfError(err), err
+	}
+	_, err = kcr.k8sClient.CoreV1().Namespaces().Get(nameOfGrant, metaV1.GetOptions{})
+	if err != nil {
+		return nil, statusOfError(err), err
+	}
+	return serviceAccount, http.StatusOK, nil
+}
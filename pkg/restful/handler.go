@@ -5,38 +5,75 @@ import (
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
 	"github.com/go-openapi/spec"
+	"github.com/starcloud-ai/kubeconfig/pkg/capability"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+	rbacV1 "k8s.io/api/rbac/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"net/http"
 )
 
-func CreateHandler(k8sClient kubernetes.Interface, prefix string, clusterCAServer string, clusterCAData []byte,
-	tillerNamespace string, tillerRole string, sriovDefaultNamespace string, swaggerUIDist string) http.Handler {
+func CreateHandler(k8sClient kubernetes.Interface, dynamicClient dynamic.Interface,
+	prefix string, clusterCAServer string, clusterCAData []byte,
+	oidcIssuerURL string, oidcClientID string,
+	helmMode string, tillerNamespace string, tillerRole string, swaggerUIDist string,
+	ceilingRules []rbacV1.PolicyRule, networks *network.Manager, detector *capability.Detector,
+	datasets *storage.Datasets, classes *storage.Classes, storageBudget *resource.Quantity,
+	namespacePatchableKeys []string) http.Handler {
 	container := restful.NewContainer()
 
-	nsr := createNameSpacesResource(k8sClient, prefix)
+	ceiling := rbac.NewCeiling(ceilingRules, k8sClient)
+	registry := rbac.NewRegistry(helmMode, tillerNamespace, tillerRole, k8sClient)
+
+	budgets := storage.NewBudgets(storageBudget, k8sClient)
+
+	nsr := createNameSpacesResource(k8sClient, prefix, networks, budgets, dynamicClient, detector, namespacePatchableKeys)
 	container.Add(nsr.WebService())
 
 	kcr := createKubeConfigResource(k8sClient,
 		clusterCAServer,
 		clusterCAData,
-		tillerNamespace,
-		tillerRole,
+		oidcIssuerURL,
+		oidcClientID,
+		registry,
+		ceiling,
 		prefix,
-		sriovDefaultNamespace)
+		networks,
+		budgets)
 	container.Add(kcr.WebService())
 
-	sar := createServiceAccountResource(k8sClient, prefix)
+	sar := createServiceAccountResource(k8sClient, prefix, registry)
 	container.Add(sar.WebService())
 
-	rr := createRoleResource(k8sClient, prefix)
+	rr := createRoleResource(k8sClient, prefix, ceiling)
 	container.Add(rr.WebService())
 
+	rbr := createRoleBindingResource(k8sClient, prefix, ceiling)
+	container.Add(rbr.WebService())
+
 	crr := createClusterRoleResource(k8sClient)
 	container.Add(crr.WebService())
 
-	pvr := createPersistVolumeResource(k8sClient, prefix)
+	pvr := createPersistVolumeResource(k8sClient, prefix, classes, budgets)
 	container.Add(pvr.WebService())
 
+	scr := createStorageClassResource(classes)
+	container.Add(scr.WebService())
+
+	dr := createDatasetResource(k8sClient, prefix, datasets)
+	container.Add(dr.WebService())
+
+	rcr := createReconcileResource(k8sClient, prefix, registry, budgets, networks)
+	container.Add(rcr.WebService())
+	go rcr.labelManagedNamespaces()
+
+	cr := createCapabilityResource(detector)
+	container.Add(cr.WebService())
+
 	config := restfulspec.Config{
 		WebServices:                   container.RegisteredWebServices(), // you control what services are visible
 		APIPath:                       "/apidocs.json",
@@ -48,7 +85,7 @@ func CreateHandler(k8sClient kubernetes.Interface, prefix string, clusterCAServe
 	// Optionally, you may need to enable CORS for the UI to work.
 	cors := restful.CrossOriginResourceSharing{
 		AllowedHeaders: []string{"Content-Type", "Accept"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		CookiesAllowed: false,
 		Container:      container}
 	container.Filter(cors.Filter)
@@ -82,6 +119,53 @@ func enrichSwaggerObject(swo *spec.Swagger) {
 		Description: "Managing namespaces"}}}
 }
 
+const (
+	// managedByLabel marks the objects created by the service
+	managedByLabel = "clustar.ai/managed-by"
+	managedByValue = "kubeconfig"
+)
+
 type Result struct {
 	Status string `json:"status" description:"action result"`
 }
+
+// statusOfError returns the status code the api server answered with, or 500 for any other error
+func statusOfError(err error) int {
+	switch t := err.(type) {
+	case *k8sError.StatusError:
+		if t.Status().Code != 0 {
+			return int(t.Status().Code)
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// statusOfCheckError answers 403 for rules beyond the ceiling, other errors come from the api server
+func statusOfCheckError(err error) int {
+	switch err.(type) {
+	case *rbac.EscalationError:
+		return http.StatusForbidden
+	}
+	return statusOfError(err)
+}
+
+// statusOfNetworkError answers 400 for selections and definitions the request got wrong
+func statusOfNetworkError(err error) int {
+	switch err.(type) {
+	case *network.UnknownDefinitionsError, *network.NotManagedError:
+		return http.StatusBadRequest
+	}
+	return statusOfError(err)
+}
+
+// statusOfStorageError answers 409 for datasets still attached to tenants,
+// 403 for classes tenants may not use and for claims beyond the budget
+func statusOfStorageError(err error) int {
+	switch err.(type) {
+	case *storage.AttachedError:
+		return http.StatusConflict
+	case *storage.ClassNotAllowedError, *storage.BudgetExceededError:
+		return http.StatusForbidden
+	}
+	return statusOfError(err)
+}
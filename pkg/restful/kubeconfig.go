@@ -1,15 +1,16 @@
 package restful
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
 	"github.com/ghodss/yaml"
-	"github.com/intel/multus-cni/types"
+	"github.com/golang/glog"
 	jsonitor "github.com/json-iterator/go"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
 	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
 	coreV1 "k8s.io/api/core/v1"
 	k8sError "k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -17,33 +18,49 @@ import (
 	k8sCliApi "k8s.io/client-go/tools/clientcmd/api/v1"
 	"net/http"
 	"strings"
+	"time"
+)
+
+const (
+	kubeConfigAuthToken = "token"
+	kubeConfigAuthOIDC  = "oidc"
+	kubeConfigAuthExec  = "exec"
 )
 
 type KubeConfigResource struct {
 	k8sClient                kubernetes.Interface
 	clusterServer            string
 	clusterCAData            []byte
-	tillerNamespace          string
-	tillerRole               string
+	oidcIssuerURL            string
+	oidcClientID             string
+	registry                 *rbac.Registry
+	ceiling                  *rbac.Ceiling
 	selfDefineResourcePrefix string
-	sriovDefaultNamespace    string
+	networks                 *network.Manager
+	budgets                  *storage.Budgets
 }
 
 func createKubeConfigResource(k8sClient kubernetes.Interface,
 	clusterServer string,
 	clusterCAData []byte,
-	tillerNamespace string,
-	tillerRole string,
+	oidcIssuerURL string,
+	oidcClientID string,
+	registry *rbac.Registry,
+	ceiling *rbac.Ceiling,
 	prefix string,
-	sriovDefaultNamespace string) (resource *KubeConfigResource) {
+	networks *network.Manager,
+	budgets *storage.Budgets) (resource *KubeConfigResource) {
 	resource = &KubeConfigResource{
 		k8sClient:                k8sClient,
 		clusterServer:            clusterServer,
 		clusterCAData:            clusterCAData,
-		tillerNamespace:          tillerNamespace,
-		tillerRole:               tillerRole,
+		oidcIssuerURL:            oidcIssuerURL,
+		oidcClientID:             oidcClientID,
+		registry:                 registry,
+		ceiling:                  ceiling,
 		selfDefineResourcePrefix: prefix,
-		sriovDefaultNamespace:    sriovDefaultNamespace,
+		networks:                 networks,
+		budgets:                  budgets,
 	}
 	return
 }
@@ -61,17 +78,21 @@ func (kcr KubeConfigResource) WebService() *restful.WebService {
 		Doc("generate kubeconfig for specified serviceAccount").
 		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
 		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Param(ws.QueryParameter("auth", "how the kubeconfig authenticates: token, oidc or exec, default is token, or exec when the serviceAccount itself is not bound").DataType("string")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(k8sCliApi.Config{}). // on the response
 		Returns(200, "OK", nil).
-		Returns(404, "Not Found", nil))
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil).
+		Returns(503, "Token secret not issued yet", nil))
 
 	ws.Route(ws.POST("/").To(kcr.createServiceAccount).
 		// docs
-		Doc("create serviceAccount").
+		Doc("create serviceAccount, or replace the profiles of an existing one").
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(serviceAccountAction{}). // on the response
 		Returns(200, "OK", nil).
+		Returns(403, "Custom role beyond the ceiling policy", nil).
 		Returns(404, "Not Found", nil))
 
 	ws.Route(ws.DELETE("/{namespace}/{serviceAccount}").To(kcr.deleteServiceAccount).
@@ -83,58 +104,71 @@ func (kcr KubeConfigResource) WebService() *restful.WebService {
 		Returns(200, "OK", nil).
 		Returns(404, "Not Found", nil))
 
-	return ws
-}
+	ws.Route(ws.GET("/{namespace}/{serviceAccount}/roles").To(kcr.describeRoles).
+		// docs
+		Doc("describe the roles the profiles of the serviceAccount bind it to, and whether they exist").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]roleStatusEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
 
-// 使用curl通过api访问集群
-// kubectl proxy --port=8080 &
-// 如果要获取所有的network-attachment-definitions访问的地址：
-// http://localhost:8080/apis/k8s.cni.cncf.io/v1/network-attachment-definitions/
-// 获取某个namespace下的资源
-// http://localhost:8080/apis/k8s.cni.cncf.io/v1/namespaces/default/network-attachment-definitions
-// 获取某个namespace下的某个资源
-// http://localhost:8080/apis/k8s.cni.cncf.io/v1/namespaces/default/network-attachment-definitions/sroiv-conf
-// read sriov-conf from default namespace
-func (kcr KubeConfigResource) getDefaultMultusConfig() (*NetworkAttachmentDefinitionList, error) {
-	rawPath := fmt.Sprintf("/apis/k8s.cni.cncf.io/v1/namespaces/%s/network-attachment-definitions", kcr.sriovDefaultNamespace)
-	netData, err := kcr.GetRawWithPath(rawPath)
-	if err != nil {
-		return nil, err
-	}
-	customResources := &NetworkAttachmentDefinitionList{}
-	if err := json.Unmarshal(netData, customResources); err != nil {
-		return nil, err
-	}
-	return customResources, nil
-}
+	ws.Route(ws.POST("/{namespace}/{serviceAccount}/rotate").To(kcr.rotateToken).
+		// docs
+		Doc("revoke the token secrets of the serviceAccount and wait for the token controller to issue a new one").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Param(ws.QueryParameter("timeout", "seconds to wait for the new token, default is 30").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(tokenRotationEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil).
+		Returns(504, "New token not issued in time", nil))
 
-func (kcr KubeConfigResource) addMultusConfig(nameOfSpace string, configs *NetworkAttachmentDefinitionList) error {
-	for _, item := range configs.Items {
-		rawPath := fmt.Sprintf("/apis/%s/namespaces/%s/network-attachment-definitions", item.APIVersion, nameOfSpace)
+	ws.Route(ws.GET("/{namespace}/{serviceAccount}/grants").To(kcr.findAllGrants).
+		// docs
+		Doc("list the other namespaces the serviceAccount has been granted").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]grantEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
 
-		definition := &types.NetworkAttachmentDefinition{}
-		definition.APIVersion = item.APIVersion
-		definition.Kind = item.Kind
-		definition.Spec = item.Spec
-		definition.Metadata.Name = item.Metadata.Name
-		definition.Metadata.Namespace = nameOfSpace
+	ws.Route(ws.PUT("/{namespace}/{serviceAccount}/grants/{grantNamespace}").To(kcr.grantNamespace).
+		// docs
+		Doc("grant the serviceAccount access to another managed namespace with a profile").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("grantNamespace", "identifier of the granted namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(grantAction{}).
+		Writes(grantEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(403, "Custom role beyond the ceiling policy", nil).
+		Returns(404, "Not Found", nil))
 
-		body, err := json.Marshal(definition)
+	ws.Route(ws.DELETE("/{namespace}/{serviceAccount}/grants/{grantNamespace}").To(kcr.revokeNamespace).
+		// docs
+		Doc("revoke the access of the serviceAccount to another managed namespace").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("grantNamespace", "identifier of the granted namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
 
-		_, err = kcr.PostRawWithPath(rawPath, body)
-		if err != nil {
-			fmt.Println(err)
-			return err
-		}
-	}
-	return nil
+	return ws
 }
 
-// GET http://localhost:8080/kubeconfig/default/default
+// GET http://localhost:8080/kubeconfig/default/default?auth=exec
 //
 func (kcr KubeConfigResource) generateKubeConfig(request *restful.Request, response *restful.Response) {
 	nameOfSpace := request.PathParameter("namespace")
 	nameOfAccount := request.PathParameter("serviceAccount")
+	auth := request.QueryParameter("auth")
 
 	serviceAccount, err := kcr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Get(nameOfAccount, metaV1.GetOptions{})
 	if err != nil {
@@ -142,12 +176,57 @@ func (kcr KubeConfigResource) generateKubeConfig(request *restful.Request, respo
 		return
 	}
 
-	secret, err := kcr.k8sClient.CoreV1().Secrets(nameOfSpace).Get(serviceAccount.Secrets[0].Name, metaV1.GetOptions{})
+	// an account which only binds users and groups has no use for the token of its ServiceAccount
+	if auth == "" {
+		auth = kubeConfigAuthToken
+		if !rbac.BindsServiceAccount(serviceAccount) {
+			auth = kubeConfigAuthExec
+		}
+	}
+
+	var authInfo k8sCliApi.AuthInfo
+	switch auth {
+	case kubeConfigAuthToken:
+		if !rbac.BindsServiceAccount(serviceAccount) {
+			response.WriteError(http.StatusBadRequest, errors.New(
+				fmt.Sprintf("serviceAccount: %s/%s is not bound, use auth oidc or exec instead!", nameOfSpace, nameOfAccount)))
+			return
+		}
+		// the token controller fills in the secrets asynchronously, after a rotation as well,
+		// and newer clusters do not create them at all
+		secret, err := kcr.issuedTokenOf(serviceAccount, nil)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		if secret == nil {
+			response.WriteError(http.StatusServiceUnavailable, errors.New(
+				fmt.Sprintf("serviceAccount: %s/%s has no token secret yet, retry later or use auth oidc or exec instead!", nameOfSpace, nameOfAccount)))
+			return
+		}
+		authInfo = k8sCliApi.AuthInfo{Token: string(secret.Data[coreV1.ServiceAccountTokenKey])}
+	case kubeConfigAuthOIDC, kubeConfigAuthExec:
+		if kcr.oidcIssuerURL == "" || kcr.oidcClientID == "" {
+			response.WriteError(http.StatusBadRequest, errors.New(
+				fmt.Sprintf("auth %s requires the oidc issuer and client id to be configured", auth)))
+			return
+		}
+		authInfo = kcr.oidcAuthInfo(auth)
+	default:
+		response.WriteError(http.StatusBadRequest, errors.New(fmt.Sprintf("unknown auth: %s", auth)))
+		return
+	}
+	config := generateConfigMap(serviceAccount.Name, authInfo, kcr.clusterServer, kcr.clusterCAData)
+
+	grants, err := kcr.listGrants(nameOfSpace, nameOfAccount)
 	if err != nil {
 		response.WriteError(http.StatusInternalServerError, err)
 		return
 	}
-	config := generateConfigMap(serviceAccount.Name, secret.Data["token"], kcr.clusterServer, kcr.clusterCAData)
+	for _, grant := range grants {
+		addNamespaceContext(config, serviceAccount.Name, grant.Namespace)
+	}
+
 	result, err := jsonitor.Marshal(config)
 	if err != nil {
 		response.WriteError(http.StatusInternalServerError, err)
@@ -159,6 +238,11 @@ func (kcr KubeConfigResource) generateKubeConfig(request *restful.Request, respo
 		response.WriteError(http.StatusInternalServerError, err)
 		return
 	}
+	// the kubeconfig is issued even when the history cannot be recorded
+	err = kcr.recordAccountTimestamp(nameOfSpace, nameOfAccount, kubeConfigIssuedAnnotation, time.Now())
+	if err != nil {
+		glog.Errorf("recording the kubeconfig issued for %s/%s: %s", nameOfSpace, nameOfAccount, err)
+	}
 	response.Write(output)
 }
 
@@ -180,9 +264,14 @@ func (kcr KubeConfigResource) createServiceAccount(request *restful.Request, res
 }
 
 func (kcr KubeConfigResource) createServiceAccountAction(action *serviceAccountAction) (int, error) {
+	statenum, err := kcr.checkSubjects(action)
+	if err != nil {
+		return statenum, errors.New(fmt.Sprintf("error while checkSubjects:%s", err))
+	}
+
 	// check if namespace is exists
 	// if not exists then create it
-	statenum, err := kcr.checkNamespace(action)
+	statenum, err = kcr.checkNamespace(action)
 	if err != nil {
 		return statenum, errors.New(fmt.Sprintf("error while checkNamespace:%s", err))
 	}
@@ -209,6 +298,17 @@ func (kcr KubeConfigResource) createServiceAccountAction(action *serviceAccountA
 	return http.StatusOK, nil
 }
 
+// the ServiceAccount is created in any case, it records the account even when it is not bound itself
+func (kcr KubeConfigResource) checkSubjects(action *serviceAccountAction) (int, error) {
+	if err := rbac.ValidateSubjectNames(action.Users, action.Groups); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if !action.bindsServiceAccount() && len(action.Users) == 0 && len(action.Groups) == 0 {
+		return http.StatusBadRequest, errors.New("an account which does not bind its serviceAccount needs users or groups")
+	}
+	return http.StatusOK, nil
+}
+
 func (kcr KubeConfigResource) checkNamespace(action *serviceAccountAction) (int, error) {
 	if !strings.HasPrefix(action.NameSpace, kcr.selfDefineResourcePrefix) {
 		return http.StatusBadRequest, errors.New(
@@ -227,10 +327,14 @@ func (kcr KubeConfigResource) checkNamespace(action *serviceAccountAction) (int,
 			namespacetmp.APIVersion = "v1"
 			namespacetmp.Kind = "Namespace"
 			namespacetmp.Name = action.NameSpace
+			namespacetmp.Labels = map[string]string{managedByLabel: managedByValue}
 			_, err = kcr.k8sClient.CoreV1().Namespaces().Create(namespacetmp)
 			if err != nil {
 				return http.StatusInternalServerError, err
 			}
+			if _, err = kcr.budgets.SyncQuota(action.NameSpace); err != nil {
+				return statusOfError(err), err
+			}
 		} else {
 			return http.StatusInternalServerError, err
 		}
@@ -239,14 +343,24 @@ func (kcr KubeConfigResource) checkNamespace(action *serviceAccountAction) (int,
 }
 
 func (kcr KubeConfigResource) checkSriov(action *serviceAccountAction) (int, error) {
-	configs, err := kcr.getDefaultMultusConfig()
+	if !kcr.networks.IsAvailable() {
+		glog.Infof("multus is not installed, skip copying network attachments into %s", action.NameSpace)
+		return http.StatusOK, nil
+	}
+	if action.NetworkAttachments != nil {
+		if err := action.NetworkAttachments.Validate(); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	definitions, err := kcr.networks.Select(action.NetworkAttachments)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return statusOfNetworkError(err), err
 	}
 
-	err = kcr.addMultusConfig(action.NameSpace, configs)
+	// copies which already exist are brought up to date, so provisioning another account of the namespace works
+	_, err = kcr.networks.Copy(action.NameSpace, definitions)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return statusOfError(err), err
 	}
 	return http.StatusOK, nil
 }
@@ -277,37 +391,127 @@ func (kcr KubeConfigResource) checkServiceAccount(action *serviceAccountAction)
 }
 
 func (kcr KubeConfigResource) checkRoleAndBinding(action *serviceAccountAction) (int, error) {
-	tillerRole := rbac.NewTillerRole(kcr.tillerNamespace, kcr.tillerRole, kcr.k8sClient)
-	adminRole := rbac.NewNamespaceAdminRole(action.NameSpace, kcr.k8sClient)
-	clusterReadonlyRole := rbac.NewClusterReadonlyRoleRole(action.NameSpace, rbac.ReadOnlyRole, kcr.k8sClient)
+	for _, profile := range action.Profiles {
+		if !rbac.IsValidProfile(profile) {
+			return http.StatusBadRequest, errors.New(fmt.Sprintf("unknown profile: %s", profile))
+		}
+	}
+	roles, err := kcr.registry.AccountRoles(action.NameSpace, action.Profiles, action.Role, action.ClusterRole)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := kcr.checkCustomRoles(roles); err != nil {
+		return statusOfCheckError(err), err
+	}
 
-	err := tillerRole.CreateRole()
+	// bindings left over from the previous profiles are replaced
+	subjects := rbac.AccountSubjects(action.NameSpace, action.ServiceAccount, action.bindsServiceAccount(), action.Users, action.Groups)
+	err = kcr.registry.Provision(action.NameSpace, action.ServiceAccount, subjects, roles)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	err = adminRole.CreateRole()
+
+	return kcr.recordProfiles(action)
+}
+
+// checkCustomRoles holds the roles of the custom profile, which are not managed by the service,
+// to the ceiling policy like the role bindings tenants create themselves
+func (kcr KubeConfigResource) checkCustomRoles(roles []rbac.RbacInterface) error {
+	for _, role := range roles {
+		if custom, ok := role.(*rbac.CustomRole); ok {
+			if err := kcr.ceiling.CheckRoleRef(custom.Namespace, custom.RoleKind, custom.RoleName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (kcr KubeConfigResource) recordProfiles(action *serviceAccountAction) (int, error) {
+	profiles := action.Profiles
+	if len(profiles) == 0 {
+		profiles = []string{rbac.DefaultProfile}
+	}
+	bindServiceAccount := ""
+	if !action.bindsServiceAccount() {
+		bindServiceAccount = "false"
+	}
+	annotations := map[string]string{
+		rbac.ProfileAnnotation:            strings.Join(profiles, ","),
+		rbac.CustomRoleAnnotation:         action.Role,
+		rbac.CustomClusterRoleAnnotation:  action.ClusterRole,
+		rbac.UsersAnnotation:              strings.Join(action.Users, ","),
+		rbac.GroupsAnnotation:             strings.Join(action.Groups, ","),
+		rbac.BindServiceAccountAnnotation: bindServiceAccount,
+	}
+
+	serviceAccount, err := kcr.k8sClient.CoreV1().ServiceAccounts(action.NameSpace).Get(action.ServiceAccount, metaV1.GetOptions{})
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	err = clusterReadonlyRole.CreateRole()
+	changed := false
+	for key, value := range annotations {
+		if serviceAccount.Annotations[key] == value {
+			continue
+		}
+		if serviceAccount.Annotations == nil {
+			serviceAccount.Annotations = map[string]string{}
+		}
+		if value == "" {
+			delete(serviceAccount.Annotations, key)
+		} else {
+			serviceAccount.Annotations[key] = value
+		}
+		changed = true
+	}
+	if !changed {
+		return http.StatusOK, nil
+	}
+	_, err = kcr.k8sClient.CoreV1().ServiceAccounts(action.NameSpace).Update(serviceAccount)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	return http.StatusOK, nil
+}
 
-	err = tillerRole.CreateRoleBinding(action.NameSpace, action.ServiceAccount)
+// GET http://localhost:8080/kubeconfig/clustar-{ns}/default/roles
+//
+func (kcr KubeConfigResource) describeRoles(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAccount := request.PathParameter("serviceAccount")
+
+	serviceAccount, err := kcr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Get(nameOfAccount, metaV1.GetOptions{})
 	if err != nil {
-		return http.StatusInternalServerError, err
+		response.WriteError(statusOfError(err), err)
+		return
 	}
-	err = adminRole.CreateRoleBinding(action.NameSpace, action.ServiceAccount)
+	profiles, customRole, customClusterRole := rbac.ProfilesOfServiceAccount(serviceAccount)
+	roles, err := kcr.registry.AccountRoles(nameOfSpace, profiles, customRole, customClusterRole)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		response.WriteError(http.StatusInternalServerError, err)
+		return
 	}
-	err = clusterReadonlyRole.CreateRoleBinding(action.NameSpace, action.ServiceAccount)
+	statuses, err := kcr.registry.Describe(nameOfSpace, nameOfAccount, roles)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		response.WriteError(statusOfError(err), err)
+		return
 	}
 
-	return http.StatusOK, nil
+	list := []roleStatusEntity{}
+	for _, each := range statuses {
+		list = append(list, roleStatusEntity{
+			RoleKind:         each.RoleKind,
+			RoleNamespace:    each.RoleNamespace,
+			RoleName:         each.RoleName,
+			BindingKind:      each.BindingKind,
+			BindingNamespace: each.BindingNamespace,
+			BindingName:      each.BindingName,
+			Managed:          each.Managed,
+			RoleExists:       each.RoleExists,
+			BindingExists:    each.BindingExists,
+		})
+	}
+	response.WriteEntity(list)
 }
 
 func (kcr KubeConfigResource) deleteServiceAccount(request *restful.Request, response *restful.Response) {
@@ -320,14 +524,8 @@ func (kcr KubeConfigResource) deleteServiceAccount(request *restful.Request, res
 		return
 	}
 
-	bindingName := rbac.GenerateRoleBindingName(rbac.ReadOnlyRole, nameOfSpace, nameOfAccount)
-	err := kcr.k8sClient.RbacV1().ClusterRoleBindings().Delete(bindingName, &metaV1.DeleteOptions{})
-	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
-		return
-	}
-	bindingName = rbac.GenerateRoleBindingName(kcr.tillerRole, nameOfSpace, nameOfAccount)
-	err = kcr.k8sClient.RbacV1().RoleBindings(kcr.tillerNamespace).Delete(bindingName, &metaV1.DeleteOptions{})
+	// grants in other namespaces are removed together with the account
+	err := kcr.registry.Deprovision(nameOfSpace, nameOfAccount)
 	if err != nil {
 		response.WriteError(http.StatusInternalServerError, err)
 		return
@@ -340,15 +538,35 @@ func (kcr KubeConfigResource) deleteServiceAccount(request *restful.Request, res
 	response.Write([]byte("{\"status\":\"success\"}"))
 }
 
-func (kcr KubeConfigResource) GetRawWithPath(path string) ([]byte, error) {
-	return kcr.k8sClient.ExtensionsV1beta1().RESTClient().Get().AbsPath(path).DoRaw()
-}
-
-func (kcr KubeConfigResource) PostRawWithPath(path string, body []byte) ([]byte, error) {
-	return kcr.k8sClient.ExtensionsV1beta1().RESTClient().Post().AbsPath(path).Body(body).DoRaw()
+// oidcAuthInfo lets kubectl authenticate against the oidc provider instead of using a token,
+// either with the built-in oidc auth provider or with the oidc-login exec plugin
+func (kcr KubeConfigResource) oidcAuthInfo(auth string) k8sCliApi.AuthInfo {
+	if auth == kubeConfigAuthOIDC {
+		return k8sCliApi.AuthInfo{
+			AuthProvider: &k8sCliApi.AuthProviderConfig{
+				Name: "oidc",
+				Config: map[string]string{
+					"idp-issuer-url": kcr.oidcIssuerURL,
+					"client-id":      kcr.oidcClientID,
+				},
+			},
+		}
+	}
+	return k8sCliApi.AuthInfo{
+		Exec: &k8sCliApi.ExecConfig{
+			APIVersion: "client.authentication.k8s.io/v1beta1",
+			Command:    "kubectl",
+			Args: []string{
+				"oidc-login",
+				"get-token",
+				fmt.Sprintf("--oidc-issuer-url=%s", kcr.oidcIssuerURL),
+				fmt.Sprintf("--oidc-client-id=%s", kcr.oidcClientID),
+			},
+		},
+	}
 }
 
-func generateConfigMap(name string, token []byte, server string, caData []byte) (confMap *k8sCliApi.Config) {
+func generateConfigMap(name string, authInfo k8sCliApi.AuthInfo, server string, caData []byte) (confMap *k8sCliApi.Config) {
 	confMap = &k8sCliApi.Config{}
 	confMap.APIVersion = "v1"
 	confMap.Kind = "Config"
@@ -361,10 +579,8 @@ func generateConfigMap(name string, token []byte, server string, caData []byte)
 		},
 	})
 	confMap.AuthInfos = append(confMap.AuthInfos, k8sCliApi.NamedAuthInfo{
-		Name: name,
-		AuthInfo: k8sCliApi.AuthInfo{
-			Token: fmt.Sprintf("%s", token),
-		},
+		Name:     name,
+		AuthInfo: authInfo,
 	})
 	confMap.Clusters = append(confMap.Clusters, k8sCliApi.NamedCluster{
 		Name: name,
@@ -376,22 +592,45 @@ func generateConfigMap(name string, token []byte, server string, caData []byte)
 	return
 }
 
+// one more context which uses the same user and cluster as the context name, but defaults to namespace
+func addNamespaceContext(confMap *k8sCliApi.Config, name string, namespace string) {
+	confMap.Contexts = append(confMap.Contexts, k8sCliApi.NamedContext{
+		Name: fmt.Sprintf("%s@%s", name, namespace),
+		Context: k8sCliApi.Context{
+			AuthInfo:  name,
+			Cluster:   name,
+			Namespace: namespace,
+		},
+	})
+}
+
 //
 type serviceAccountAction struct {
-	NameSpace      string `json:"namespace" description:"name of the namespace"`
-	ServiceAccount string `json:"serviceaccount" description:"name of the service account"`
-	//Role           string  `json:"role,omitempty" description:"role bind to service account"`
-	//ClusterRole    string  `json:"clusterole,omitempty" description:"cluster role bind to service account"`
+	NameSpace      string   `json:"namespace" description:"name of the namespace"`
+	ServiceAccount string   `json:"serviceaccount" description:"name of the service account"`
+	Profiles       []string `json:"profiles,omitempty" description:"access profiles of the service account: viewer, editor, admin or custom, default is admin"`
+	Role           string   `json:"role,omitempty" description:"existing role bind to service account by the custom profile"`
+	ClusterRole    string   `json:"clusterrole,omitempty" description:"existing cluster role bind to service account inside its namespace by the custom profile"`
+	Users          []string `json:"users,omitempty" description:"oidc users bound together with the service account"`
+	Groups         []string `json:"groups,omitempty" description:"oidc groups bound together with the service account"`
+	// a pointer, so that leaving it out keeps the default
+	BindServiceAccount *bool `json:"bindServiceAccount,omitempty" description:"whether the service account itself is bound, default is true, set false to only grant the users and groups"`
+	// nil copies the default network attachments, an empty selection copies none
+	NetworkAttachments *network.Selection `json:"networkAttachments,omitempty" description:"network attachment definitions copied into the namespace, default is the configured default set"`
 }
 
-type NetworkAttachmentDefinitionList struct {
-	metaV1.TypeMeta `json:",inline"`
-	// Standard list metadata.
-	// More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds
-	// +optional
-	metaV1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+func (action *serviceAccountAction) bindsServiceAccount() bool {
+	return action.BindServiceAccount == nil || *action.BindServiceAccount
+}
 
-	// Items is the list of Namespace objects in the list.
-	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/namespaces/
-	Items []types.NetworkAttachmentDefinition `json:"items" protobuf:"bytes,2,rep,name=items"`
+type roleStatusEntity struct {
+	RoleKind         string `json:"roleKind" description:"Role or ClusterRole"`
+	RoleNamespace    string `json:"roleNamespace,omitempty" description:"namespace of the role"`
+	RoleName         string `json:"roleName" description:"name of the role"`
+	BindingKind      string `json:"bindingKind" description:"RoleBinding or ClusterRoleBinding"`
+	BindingNamespace string `json:"bindingNamespace,omitempty" description:"namespace of the binding"`
+	BindingName      string `json:"bindingName" description:"name of the binding"`
+	Managed          bool   `json:"managed" description:"whether the role is created and reconciled by the service"`
+	RoleExists       bool   `json:"roleExists" description:"whether the role exists"`
+	BindingExists    bool   `json:"bindingExists" description:"whether the binding exists"`
 }
@@ -0,0 +1,178 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// namespacePhaseDeleted is reported once the namespace is gone, kubernetes has no phase for it
+	namespacePhaseDeleted = "Deleted"
+
+	defaultDeletionTimeout = 120 * time.Second
+	deletionPollInterval   = 2 * time.Second
+)
+
+type namespaceConditionEntity struct {
+	Type               string `json:"type" description:"type of the condition, such as NamespaceDeletionContentFailure"`
+	Status             string `json:"status" description:"True, False or Unknown"`
+	Reason             string `json:"reason,omitempty" description:"reason of the condition"`
+	Message            string `json:"message,omitempty" description:"what the namespace controller met"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty" description:"when the condition changed"`
+}
+
+type finalizerHolderEntity struct {
+	Resource   string   `json:"resource" description:"resource of the object, as resource.group"`
+	Name       string   `json:"name" description:"name of the object"`
+	Finalizers []string `json:"finalizers" description:"finalizers the object waits for"`
+}
+
+type namespaceDeletionEntity struct {
+	Namespace         string                     `json:"namespace" description:"name of the namespace"`
+	UID               string                     `json:"uid,omitempty" description:"uid of the namespace being deleted, a namespace recreated under the same name has another one"`
+	Phase             string                     `json:"phase" description:"Active, Terminating or Deleted"`
+	DeletionTimestamp string                     `json:"deletionTimestamp,omitempty" description:"when the deletion was requested"`
+	StatusURL         string                     `json:"statusURL" description:"where the deletion can be followed"`
+	Finalizers        []string                   `json:"finalizers,omitempty" description:"finalizers of the namespace itself"`
+	Conditions        []namespaceConditionEntity `json:"conditions,omitempty" description:"conditions the namespace controller reported"`
+	Remaining         map[string]int             `json:"remaining,omitempty" description:"number of objects left, by resource.group"`
+	FinalizerHolders  []finalizerHolderEntity    `json:"finalizerHolders,omitempty" description:"objects left which wait for finalizers"`
+	Errors            []string                   `json:"errors,omitempty" description:"resources which could not be looked at"`
+}
+
+func (nsr NameSpacesResource) newNamespaceDeletionEntity(nameOfSpace string, namespace *coreV1.Namespace) *namespaceDeletionEntity {
+	entity := &namespaceDeletionEntity{
+		Namespace: nameOfSpace,
+		Phase:     namespacePhaseDeleted,
+		StatusURL: fmt.Sprintf("/namespaces/%s/deletion", nameOfSpace),
+	}
+	if namespace == nil {
+		return entity
+	}
+	entity.UID = string(namespace.UID)
+	entity.StatusURL = fmt.Sprintf("/namespaces/%s/deletion?uid=%s", nameOfSpace, namespace.UID)
+	entity.Phase = string(namespace.Status.Phase)
+	if namespace.DeletionTimestamp != nil {
+		entity.DeletionTimestamp = namespace.DeletionTimestamp.Format(time.RFC3339)
+	}
+	for _, finalizer := range namespace.Spec.Finalizers {
+		entity.Finalizers = append(entity.Finalizers, string(finalizer))
+	}
+	for _, condition := range namespace.Status.Conditions {
+		entity.Conditions = append(entity.Conditions, namespaceConditionEntity{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime.Format(time.RFC3339),
+		})
+	}
+	return entity
+}
+
+// diagnoseDeletion looks at every namespaced resource of the cluster for what is left inside the namespace,
+// resources which cannot be discovered or which the service may not list are reported instead of failing the whole diagnosis
+func (nsr NameSpacesResource) diagnoseDeletion(entity *namespaceDeletionEntity) {
+	entity.Remaining = map[string]int{}
+	resourceLists, err := discovery.ServerPreferredNamespacedResources(nsr.k8sClient.Discovery())
+	if err != nil {
+		entity.Errors = append(entity.Errors, err.Error())
+	}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			entity.Errors = append(entity.Errors, err.Error())
+			continue
+		}
+		for _, apiResource := range resourceList.APIResources {
+			if strings.Contains(apiResource.Name, "/") || !hasVerb(apiResource.Verbs, "list") {
+				continue
+			}
+			resourceName := apiResource.Name
+			if gv.Group != "" {
+				resourceName = fmt.Sprintf("%s.%s", apiResource.Name, gv.Group)
+			}
+			objects, err := nsr.dynamicClient.Resource(gv.WithResource(apiResource.Name)).Namespace(entity.Namespace).
+				List(metaV1.ListOptions{})
+			if err != nil {
+				entity.Errors = append(entity.Errors, fmt.Sprintf("%s: %s", resourceName, err))
+				continue
+			}
+			if len(objects.Items) == 0 {
+				continue
+			}
+			entity.Remaining[resourceName] = len(objects.Items)
+			for _, object := range objects.Items {
+				if len(object.GetFinalizers()) > 0 {
+					entity.FinalizerHolders = append(entity.FinalizerHolders, finalizerHolderEntity{
+						Resource:   resourceName,
+						Name:       object.GetName(),
+						Finalizers: object.GetFinalizers(),
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(entity.FinalizerHolders, func(i, j int) bool {
+		if entity.FinalizerHolders[i].Resource != entity.FinalizerHolders[j].Resource {
+			return entity.FinalizerHolders[i].Resource < entity.FinalizerHolders[j].Resource
+		}
+		return entity.FinalizerHolders[i].Name < entity.FinalizerHolders[j].Name
+	})
+}
+
+func hasVerb(verbs metaV1.Verbs, verb string) bool {
+	for _, each := range verbs {
+		if each == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// getNamespaceOrNil returns nil once the namespace is gone, or once it has been recreated when uid is given
+func (nsr NameSpacesResource) getNamespaceOrNil(nameOfSpace string, uid string) (*coreV1.Namespace, error) {
+	namespace, err := nsr.k8sClient.CoreV1().Namespaces().Get(nameOfSpace, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		return nil, nil
+	}
+	if err == nil && uid != "" && string(namespace.UID) != uid {
+		return nil, nil
+	}
+	return namespace, err
+}
+
+// GET http://localhost:8080/namespaces/clustar-{ns}/deletion?uid=
+//
+func (nsr NameSpacesResource) findNamespaceDeletion(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	if !strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", nameOfSpace)))
+		return
+	}
+	uid := request.QueryParameter("uid")
+	namespace, err := nsr.getNamespaceOrNil(nameOfSpace, uid)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	entity := nsr.newNamespaceDeletionEntity(nameOfSpace, namespace)
+	if namespace == nil {
+		entity.UID = uid
+	} else if namespace.DeletionTimestamp != nil {
+		nsr.diagnoseDeletion(entity)
+	}
+	response.WriteEntity(entity)
+}
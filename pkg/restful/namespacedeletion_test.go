@@ -0,0 +1,62 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFindNamespaceDeletion(t *testing.T) {
+	client := fake.NewSimpleClientset(&coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{Name: "clustar-a", UID: "recreated"},
+		Status:     coreV1.NamespaceStatus{Phase: coreV1.NamespaceActive},
+	})
+	container := restful.NewContainer()
+	container.Add(createNameSpacesResource(client, "clustar-", network.NewManager("default", nil, nil),
+		nil, nil, nil, nil).WebService())
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		phase  string
+		uid    string
+	}{
+		{name: "active namespace", path: "/namespaces/clustar-a/deletion", status: http.StatusOK,
+			phase: string(coreV1.NamespaceActive), uid: "recreated"},
+		{name: "recreated namespace", path: "/namespaces/clustar-a/deletion?uid=deleted", status: http.StatusOK,
+			phase: namespacePhaseDeleted, uid: "deleted"},
+		{name: "gone namespace", path: "/namespaces/clustar-b/deletion", status: http.StatusOK,
+			phase: namespacePhaseDeleted},
+		{name: "namespace not managed by the service", path: "/namespaces/kube-system/deletion", status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+			if recorder.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.status, recorder.Body.String())
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			entity := namespaceDeletionEntity{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &entity); err != nil {
+				t.Fatal(err)
+			}
+			if entity.Phase != test.phase || entity.UID != test.uid {
+				t.Errorf("phase and uid = %s %s, want %s %s", entity.Phase, entity.UID, test.phase, test.uid)
+			}
+			if entity.Remaining != nil {
+				t.Errorf("remaining objects diagnosed without a deletion: %v", entity.Remaining)
+			}
+		})
+	}
+}
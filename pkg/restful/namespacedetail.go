@@ -0,0 +1,96 @@
+package restful
+
+import (
+	"strings"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serviceAnnotationPrefix is the domain of the annotations the service records who owns an object with
+const serviceAnnotationPrefix = "clustar.ai/"
+
+type namespaceListEntity struct {
+	Namespaces []namespaceEntity `json:"namespaces" description:"namespaces of the page"`
+	Continue   string            `json:"continue,omitempty" description:"token of the next page, empty on the last one"`
+}
+
+type namespaceEntity struct {
+	Name         string                 `json:"name" description:"name of the namespace"`
+	Managed      bool                   `json:"managed" description:"whether the namespace is managed by the service"`
+	Phase        string                 `json:"phase,omitempty" description:"phase of the namespace"`
+	CreationTime string                 `json:"creationTime,omitempty" description:"when the namespace was created"`
+	Labels       map[string]string      `json:"labels,omitempty" description:"labels of the namespace"`
+	Annotations  map[string]string      `json:"annotations,omitempty" description:"annotations of the service, recording who owns the namespace"`
+	Quota        map[string]quotaEntity `json:"quota,omitempty" description:"hard and used quantities of every resource quota, by resource"`
+	Accounts     *int                   `json:"accounts,omitempty" description:"number of service accounts, without the default one"`
+}
+
+type quotaEntity struct {
+	Hard string `json:"hard" description:"quantity allowed by the quota"`
+	Used string `json:"used" description:"quantity used"`
+}
+
+// isManagedNamespace tells if namespace carries the prefix and the label of the service,
+// namespaces created before the label are labeled at startup and by the reconcile rollout
+func isManagedNamespace(prefix string, namespace *coreV1.Namespace) bool {
+	return strings.HasPrefix(namespace.Name, prefix) && namespace.Labels[managedByLabel] == managedByValue
+}
+
+// newNamespaceEntity returns the details of a namespace, its quotas and accounts are listed for it
+func (nsr NameSpacesResource) newNamespaceEntity(namespace *coreV1.Namespace) (namespaceEntity, error) {
+	entity := namespaceEntity{
+		Name:         namespace.Name,
+		Managed:      isManagedNamespace(nsr.selfDefineResourcePrefix, namespace),
+		Phase:        string(namespace.Status.Phase),
+		CreationTime: namespace.CreationTimestamp.Format(time.RFC3339),
+		Labels:       namespace.Labels,
+		Annotations:  map[string]string{},
+		Quota:        map[string]quotaEntity{},
+	}
+	for key, value := range namespace.Annotations {
+		if strings.HasPrefix(key, serviceAnnotationPrefix) {
+			entity.Annotations[key] = value
+		}
+	}
+
+	quotas, err := nsr.k8sClient.CoreV1().ResourceQuotas(namespace.Name).List(metaV1.ListOptions{})
+	if err != nil {
+		return entity, err
+	}
+	entity.Quota = summarizeQuotas(quotas.Items)
+
+	serviceAccounts, err := nsr.k8sClient.CoreV1().ServiceAccounts(namespace.Name).List(metaV1.ListOptions{})
+	if err != nil {
+		return entity, err
+	}
+	accounts := 0
+	for _, each := range serviceAccounts.Items {
+		if each.Name != "default" {
+			accounts++
+		}
+	}
+	entity.Accounts = &accounts
+	return entity, nil
+}
+
+// summarizeQuotas merges the quotas of a namespace, the tightest hard limit of a resource applies
+func summarizeQuotas(quotas []coreV1.ResourceQuota) map[string]quotaEntity {
+	hard := coreV1.ResourceList{}
+	used := coreV1.ResourceList{}
+	for _, quota := range quotas {
+		for name, quantity := range quota.Status.Hard {
+			if current, ok := hard[name]; !ok || quantity.Cmp(current) < 0 {
+				hard[name] = quantity
+				used[name] = quota.Status.Used[name]
+			}
+		}
+	}
+	summary := map[string]quotaEntity{}
+	for name, quantity := range hard {
+		usedQuantity := used[name]
+		summary[string(name)] = quotaEntity{Hard: quantity.String(), Used: usedQuantity.String()}
+	}
+	return summary
+}
@@ -0,0 +1,150 @@
+package restful
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const mimeMergePatch = "application/merge-patch+json"
+
+// protectedKeyPrefixes are never changed through the service, whatever the allow list says:
+// the records of the service itself and the pod security admission of the namespace
+var protectedKeyPrefixes = []string{serviceAnnotationPrefix, "pod-security.kubernetes.io/"}
+
+// namespacePatch is a json merge patch of the labels and annotations of a namespace, null removes a key
+type namespacePatch struct {
+	Metadata struct {
+		Labels      map[string]*string `json:"labels,omitempty" description:"labels to set, null removes the label"`
+		Annotations map[string]*string `json:"annotations,omitempty" description:"annotations to set, null removes the annotation"`
+	} `json:"metadata" description:"metadata of the namespace"`
+}
+
+// isPatchableKey tells if tenants may set or remove the label or annotation key.
+// An empty allow list allows no key at all, entries ending with * allow a prefix and * alone every key which is not protected.
+func (nsr NameSpacesResource) isPatchableKey(key string) bool {
+	for _, prefix := range protectedKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	for _, allowed := range nsr.patchableKeys {
+		if allowed == key || (strings.HasSuffix(allowed, "*") && strings.HasPrefix(key, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// readNamespacePatch decodes the body, anything but labels and annotations is refused
+func readNamespacePatch(request *restful.Request) (*namespacePatch, error) {
+	body, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, errors.New(fmt.Sprintf("only metadata.labels and metadata.annotations can be patched: %s", err))
+	}
+	for field, metadata := range fields {
+		if field != "metadata" {
+			return nil, errors.New(fmt.Sprintf("only metadata.labels and metadata.annotations can be patched, not %s", field))
+		}
+		for each := range metadata {
+			if each != "labels" && each != "annotations" {
+				return nil, errors.New(fmt.Sprintf("only metadata.labels and metadata.annotations can be patched, not metadata.%s", each))
+			}
+		}
+	}
+	patch := &namespacePatch{}
+	if err := json.Unmarshal(body, patch); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// PATCH http://localhost:8080/namespaces/clustar-{ns}
+//
+func (nsr NameSpacesResource) patchNamespace(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	if !strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", nameOfSpace)))
+		return
+	}
+	patch, err := readNamespacePatch(request)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	var denied []string
+	for key := range patch.Metadata.Labels {
+		if !nsr.isPatchableKey(key) {
+			denied = append(denied, "label "+key)
+		}
+	}
+	for key := range patch.Metadata.Annotations {
+		if !nsr.isPatchableKey(key) {
+			denied = append(denied, "annotation "+key)
+		}
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		response.WriteError(http.StatusForbidden, errors.New(
+			fmt.Sprintf("tenants may not change %s", strings.Join(denied, ", "))))
+		return
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		namespace, err := nsr.k8sClient.CoreV1().Namespaces().Get(nameOfSpace, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		namespace.Labels = mergePatch(namespace.Labels, patch.Metadata.Labels)
+		namespace.Annotations = mergePatch(namespace.Annotations, patch.Metadata.Annotations)
+		_, err = nsr.k8sClient.CoreV1().Namespaces().Update(namespace)
+		return err
+	})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+
+	namespace, err := nsr.k8sClient.CoreV1().Namespaces().Get(nameOfSpace, metaV1.GetOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	entity, err := nsr.newNamespaceEntity(namespace)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(entity)
+}
+
+func mergePatch(current map[string]string, patch map[string]*string) map[string]string {
+	if len(patch) == 0 {
+		return current
+	}
+	if current == nil {
+		current = map[string]string{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(current, key)
+		} else {
+			current[key] = *value
+		}
+	}
+	return current
+}
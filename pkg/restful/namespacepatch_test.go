@@ -0,0 +1,28 @@
+package restful
+
+import "testing"
+
+func TestIsPatchableKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		patchable []string
+		key       string
+		allowed   bool
+	}{
+		{name: "empty allow list", key: "team"},
+		{name: "listed key", patchable: []string{"team"}, key: "team", allowed: true},
+		{name: "unlisted key", patchable: []string{"team"}, key: "owner"},
+		{name: "listed prefix", patchable: []string{"example.com/*"}, key: "example.com/owner", allowed: true},
+		{name: "every key", patchable: []string{"*"}, key: "owner", allowed: true},
+		{name: "protected key of the service", patchable: []string{"*"}, key: managedByLabel},
+		{name: "protected pod security key", patchable: []string{"*"}, key: "pod-security.kubernetes.io/enforce"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nsr := NameSpacesResource{patchableKeys: test.patchable}
+			if allowed := nsr.isPatchableKey(test.key); allowed != test.allowed {
+				t.Errorf("isPatchableKey(%s) = %v, want %v", test.key, allowed, test.allowed)
+			}
+		})
+	}
+}
@@ -4,24 +4,43 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/capability"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
 	coreV1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
 type NameSpacesResource struct {
 	k8sClient                kubernetes.Interface
 	selfDefineResourcePrefix string
+	networks                 *network.Manager
+	budgets                  *storage.Budgets
+	dynamicClient            dynamic.Interface
+	detector                 *capability.Detector
+	// patchableKeys are the labels and annotations tenants may change, empty allows none
+	patchableKeys []string
 }
 
-func createNameSpacesResource(k8sclient kubernetes.Interface, prefix string) (resource *NameSpacesResource) {
+func createNameSpacesResource(k8sclient kubernetes.Interface, prefix string, networks *network.Manager,
+	budgets *storage.Budgets, dynamicClient dynamic.Interface, detector *capability.Detector,
+	patchableKeys []string) (resource *NameSpacesResource) {
 	resource = &NameSpacesResource{
 		k8sClient:                k8sclient,
 		selfDefineResourcePrefix: prefix,
+		networks:                 networks,
+		budgets:                  budgets,
+		dynamicClient:            dynamicClient,
+		detector:                 detector,
+		patchableKeys:            patchableKeys,
 	}
 	return
 }
@@ -36,10 +55,29 @@ func (nsr NameSpacesResource) WebService() *restful.WebService {
 
 	ws.Route(ws.GET("/").To(nsr.findAllNamespaces).
 		// docs
-		Doc("get all namespaces").
+		Doc("get the names of all namespaces, or namespace entries page by page when a limit, a continue token or the details are asked for").
+		Param(ws.QueryParameter("managed", fmt.Sprintf("only the namespaces which prefix is %s and which are labeled %s=%s", nsr.selfDefineResourcePrefix, managedByLabel, managedByValue)).DataType("boolean")).
+		Param(ws.QueryParameter("prefix", "only the namespaces which name starts with the prefix").DataType("string")).
+		Param(ws.QueryParameter("labelSelector", "only the namespaces which labels match the selector").DataType("string")).
+		Param(ws.QueryParameter("limit", "at most so many namespaces, the rest is listed through continue, a page is only short when it is the last one").DataType("integer")).
+		Param(ws.QueryParameter("continue", "token of the next page returned by the previous one").DataType("string")).
+		Param(ws.QueryParameter("detail", "phase, creation time, annotations of the service, quotas and account count of every namespace").DataType("boolean")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
-		Writes([]string{}).
-		Returns(200, "OK", []string{}))
+		Writes(namespaceListEntity{}). // on the response
+		Returns(200, "OK", namespaceListEntity{}).
+		Returns(400, "Bad Request", nil).
+		Returns(410, "Continue token expired", nil))
+
+	ws.Route(ws.GET("/usage").To(nsr.findAllUsage).
+		// docs
+		Doc(fmt.Sprintf("get the resource usage of every namespace which prefix is %s and which is labeled %s=%s as a table",
+			nsr.selfDefineResourcePrefix, managedByLabel, managedByValue)).
+		Param(ws.QueryParameter("sortBy", "namespace, pods, cpuRequests, memoryRequests, gpuRequests, cpuLimits, memoryLimits, gpuLimits, storage, persistentVolumeClaims, services or loadBalancers").DataType("string").DefaultValue("namespace")).
+		Param(ws.QueryParameter("order", "asc or desc").DataType("string").DefaultValue("asc")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]usageEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil))
 
 	ws.Route(ws.GET("/{namespace}").To(nsr.findNamespace).
 		// docs
@@ -50,6 +88,94 @@ func (nsr NameSpacesResource) WebService() *restful.WebService {
 		Returns(200, "OK", nil).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(ws.GET("/{namespace}/permissions").To(nsr.findPermissions).
+		// docs
+		Doc("get the effective permissions inside a namespace as a subject x resource x verb matrix").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.QueryParameter("subject", "only the subject formatted as Kind/Namespace/Name or Kind/Name").DataType("string")).
+		Param(ws.QueryParameter("resource", "only permissions on the resource").DataType("string")).
+		Param(ws.QueryParameter("verb", "only permissions with the verb").DataType("string")).
+		Param(ws.QueryParameter("format", "json or csv").DataType("string").DefaultValue("json")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Produces(restful.MIME_JSON, "text/csv").
+		Writes(permissionMatrixEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{namespace}/usage").To(nsr.findUsage).
+		// docs
+		Doc("get the pods, requested resources, storage, services, tfjobs and quota usage of a namespace").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(usageEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{namespace}/network-attachments").To(nsr.findAllNetworkAttachments).
+		// docs
+		Doc("get the network attachment definitions copied into a namespace by the service").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]networkAttachmentEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(503, "Service Unavailable", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/{namespace}/network-attachments").To(nsr.addNetworkAttachments).
+		// docs
+		Doc(fmt.Sprintf("copy network attachment definitions of %s into a namespace", nsr.networks.SourceNamespace)).
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(network.Selection{}).
+		Writes([]network.SyncResult{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(503, "Service Unavailable", nil).
+		Returns(400, "Bad Request", nil))
+
+	ws.Route(ws.DELETE("/{namespace}/network-attachments/{attachment}").To(nsr.removeNetworkAttachment).
+		// docs
+		Doc("remove a network attachment definition copied into a namespace by the service").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Param(ws.PathParameter("attachment", "name of the network attachment definition").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", nil).
+		Returns(503, "Service Unavailable", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/{namespace}/network-attachments/{attachment}/sync").To(nsr.syncNetworkAttachment).
+		// docs
+		Doc("bring a copied network attachment definition back in line with its source, customised copies need force").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Param(ws.PathParameter("attachment", "name of the network attachment definition").DataType("string")).
+		Param(ws.QueryParameter("force", "overwrite or delete the copy even if it has been customised").DataType("boolean").DefaultValue("false")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(network.SyncResult{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(503, "Service Unavailable", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{namespace}/storage-budget").To(nsr.findStorageBudget).
+		// docs
+		Doc("get the storage a namespace may claim and how much its claims request").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(storageBudgetEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.PUT("/{namespace}/storage-budget").To(nsr.updateStorageBudget).
+		// docs
+		Doc("set the storage budget of a namespace, its requests.storage quota follows").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(storageBudgetAction{}).
+		Writes(storageBudgetEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
 	ws.Route(ws.PUT("/{namespace}").To(nsr.createNamespace).
 		// docs
 		Doc("create a namespace").
@@ -60,32 +186,127 @@ func (nsr NameSpacesResource) WebService() *restful.WebService {
 		Returns(200, "OK", nil).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(ws.PATCH("/{namespace}").To(nsr.patchNamespace).
+		// docs
+		Doc("change the labels and annotations of a namespace through a json merge patch, only allowed keys may be changed").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Consumes(restful.MIME_JSON, mimeMergePatch).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(namespacePatch{}).
+		Writes(namespaceEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(403, "Key not allowed", nil).
+		Returns(404, "Not Found", nil))
+
 	ws.Route(ws.DELETE("/{namespace}").To(nsr.removeNamespace).
 		// docs
-		Doc(fmt.Sprintf("delete a namespace which prefix is %s", nsr.selfDefineResourcePrefix)).
+		Doc(fmt.Sprintf("delete a namespace which prefix is %s, the deletion is followed through the returned status url", nsr.selfDefineResourcePrefix)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
-		Writes("").
+		Param(ws.QueryParameter("wait", "wait until the namespace is gone").DataType("boolean").DefaultValue("false")).
+		Param(ws.QueryParameter("timeout", "seconds to wait, 120 by default").DataType("integer")).
+		Writes(namespaceDeletionEntity{}).
 		Returns(200, "OK", nil).
-		Returns(404, "Not Found", nil))
+		Returns(404, "Not Found", nil).
+		Returns(504, "Still terminating", namespaceDeletionEntity{}))
+
+	ws.Route(ws.GET("/{namespace}/deletion").To(nsr.findNamespaceDeletion).
+		// docs
+		Doc("get the deletion status of a namespace: phase, conditions, and while it terminates the objects left and the finalizers they wait for").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Param(ws.QueryParameter("uid", "uid of the deleted namespace, a namespace recreated since then is reported as Deleted").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(namespaceDeletionEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil))
 
 	return ws
 }
 
-// GET http://localhost:8080/namespaces
+// GET http://localhost:8080/namespaces?managed=true&prefix=clustar-&labelSelector=team=ml&limit=20&continue=&detail=true
 //
 func (nsr NameSpacesResource) findAllNamespaces(request *restful.Request, response *restful.Response) {
-	namespaces, err := nsr.k8sClient.CoreV1().Namespaces().List(metaV1.ListOptions{})
-	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+	managed := request.QueryParameter("managed") == "true"
+	prefix := request.QueryParameter("prefix")
+	detail := request.QueryParameter("detail") == "true"
+	options := metaV1.ListOptions{
+		LabelSelector: request.QueryParameter("labelSelector"),
+		Continue:      request.QueryParameter("continue"),
+	}
+	// the label is matched by the api server, only the prefixes are left to filter here
+	if managed {
+		managedSelector := fmt.Sprintf("%s=%s", managedByLabel, managedByValue)
+		if options.LabelSelector == "" {
+			options.LabelSelector = managedSelector
+		} else {
+			options.LabelSelector = options.LabelSelector + "," + managedSelector
+		}
+	}
+	var limit int64
+	if t := request.QueryParameter("limit"); t != "" {
+		var err error
+		limit, err = strconv.ParseInt(t, 10, 64)
+		if err != nil || limit <= 0 {
+			response.WriteError(http.StatusBadRequest, errors.New(fmt.Sprintf("invalid limit: %s", t)))
+			return
+		}
+	}
+
+	// pages are listed until the limit is reached, asking each time for no more than is missing
+	// so that no namespace is skipped between the page returned and the next one
+	var selected []coreV1.Namespace
+	for {
+		if limit > 0 {
+			options.Limit = limit - int64(len(selected))
+		}
+		namespaces, err := nsr.k8sClient.CoreV1().Namespaces().List(options)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		for _, each := range namespaces.Items {
+			if managed && !strings.HasPrefix(each.Name, nsr.selfDefineResourcePrefix) {
+				continue
+			}
+			if !strings.HasPrefix(each.Name, prefix) {
+				continue
+			}
+			selected = append(selected, each)
+		}
+		options.Continue = namespaces.Continue
+		if options.Continue == "" || limit == 0 || int64(len(selected)) >= limit {
+			break
+		}
+	}
+
+	// the bare list of names is kept for callers which neither page nor ask for details
+	if limit == 0 && request.QueryParameter("continue") == "" && !detail {
+		list := []string{}
+		for _, each := range selected {
+			list = append(list, each.Name)
+		}
+		response.WriteEntity(list)
 		return
 	}
 
-	var list []string
-	for _, each := range namespaces.Items {
-		list = append(list, each.Name)
+	result := namespaceListEntity{Namespaces: []namespaceEntity{}, Continue: options.Continue}
+	for i := range selected {
+		entity := namespaceEntity{
+			Name:    selected[i].Name,
+			Managed: isManagedNamespace(nsr.selfDefineResourcePrefix, &selected[i]),
+		}
+		if detail {
+			var err error
+			entity, err = nsr.newNamespaceEntity(&selected[i])
+			if err != nil {
+				response.WriteError(statusOfError(err), err)
+				return
+			}
+		}
+		result.Namespaces = append(result.Namespaces, entity)
 	}
-	response.WriteEntity(list)
+	response.WriteEntity(result)
 }
 
 // GET http://localhost:8080/namespaces/default
@@ -113,27 +334,67 @@ func (nsr *NameSpacesResource) createNamespace(request *restful.Request, respons
 	namespaceTmp.APIVersion = "v1"
 	namespaceTmp.Kind = "Namespace"
 	namespaceTmp.Name = nameOfSpace
+	namespaceTmp.Labels = map[string]string{managedByLabel: managedByValue}
 	namespaceTmp, err := nsr.k8sClient.CoreV1().Namespaces().Create(namespaceTmp)
 	if err != nil {
 		response.WriteError(http.StatusInternalServerError, err)
-	} else {
-		response.WriteEntity(namespaceTmp)
+		return
+	}
+	if _, err := nsr.budgets.SyncQuota(nameOfSpace); err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
 	}
+	response.WriteEntity(namespaceTmp)
 }
 
-// DELETE http://localhost:8080/namespaces/clustar-{name}
+// DELETE http://localhost:8080/namespaces/clustar-{name}?wait=true&timeout=120
 //
 func (nsr *NameSpacesResource) removeNamespace(request *restful.Request, response *restful.Response) {
 	nameOfSpace := request.PathParameter("namespace")
-	if strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
-		err := nsr.k8sClient.CoreV1().Namespaces().Delete(nameOfSpace, &metaV1.DeleteOptions{})
-		if err != nil {
-			response.WriteError(http.StatusInternalServerError, err)
-			return
-		}
-		response.Write([]byte("{\"status\":\"success\"}"))
-	} else {
+	if !strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
 		response.WriteError(http.StatusBadRequest,
 			errors.New(fmt.Sprintf("namespace: %s is not self define resouce, cannot remove through service!", nameOfSpace)))
+		return
+	}
+	waitForDeletion := request.QueryParameter("wait") == "true"
+	timeout, err := timeoutParameter(request, defaultDeletionTimeout)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = nsr.k8sClient.CoreV1().Namespaces().Delete(nameOfSpace, &metaV1.DeleteOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	namespace, err := nsr.getNamespaceOrNil(nameOfSpace, "")
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if !waitForDeletion || namespace == nil {
+		response.WriteEntity(nsr.newNamespaceDeletionEntity(nameOfSpace, namespace))
+		return
+	}
+
+	uid := string(namespace.UID)
+	err = wait.PollImmediate(deletionPollInterval, timeout, func() (bool, error) {
+		namespace, err = nsr.getNamespaceOrNil(nameOfSpace, uid)
+		return namespace == nil, err
+	})
+	if err == wait.ErrWaitTimeout {
+		// still terminating, tell what it is waiting for
+		entity := nsr.newNamespaceDeletionEntity(nameOfSpace, namespace)
+		nsr.diagnoseDeletion(entity)
+		response.WriteHeaderAndEntity(http.StatusGatewayTimeout, entity)
+		return
+	}
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
 	}
+	entity := nsr.newNamespaceDeletionEntity(nameOfSpace, nil)
+	entity.UID = uid
+	response.WriteEntity(entity)
 }
@@ -0,0 +1,116 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/capability"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFindAllNamespaces(t *testing.T) {
+	managedLabels := map[string]string{managedByLabel: managedByValue}
+	client := fake.NewSimpleClientset(
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-a", Labels: managedLabels}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-b"}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "other", Labels: managedLabels}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "kube-system"}},
+	)
+	container := restful.NewContainer()
+	container.Add(createNameSpacesResource(client, "clustar-", network.NewManager("default", nil, nil),
+		nil, nil, nil, nil).WebService())
+
+	tests := []struct {
+		name    string
+		query   string
+		names   []string
+		managed []string
+	}{
+		{name: "all", query: "limit=10", names: []string{"clustar-a", "clustar-b", "kube-system", "other"}, managed: []string{"clustar-a"}},
+		{name: "managed", query: "limit=10&managed=true", names: []string{"clustar-a"}, managed: []string{"clustar-a"}},
+		{name: "prefix", query: "limit=10&prefix=clustar-", names: []string{"clustar-a", "clustar-b"}, managed: []string{"clustar-a"}},
+		{name: "no match", query: "limit=10&prefix=none", names: []string{}, managed: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/?"+test.query, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+			}
+			list := namespaceListEntity{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+				t.Fatal(err)
+			}
+			names, managed := []string{}, []string{}
+			for _, each := range list.Namespaces {
+				names = append(names, each.Name)
+				if each.Managed {
+					managed = append(managed, each.Name)
+				}
+			}
+			// the api server lists by name, the fake clientset in the order of creation
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.names) || !reflect.DeepEqual(managed, test.managed) {
+				t.Errorf("namespaces = %v managed %v, want %v managed %v", names, managed, test.names, test.managed)
+			}
+		})
+	}
+}
+
+func TestFindAllNamespaceNames(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-a"}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "kube-system"}},
+	)
+	container := restful.NewContainer()
+	container.Add(createNameSpacesResource(client, "clustar-", network.NewManager("default", nil, nil),
+		nil, nil, nil, nil).WebService())
+
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var names []string
+	if err := json.Unmarshal(recorder.Body.Bytes(), &names); err != nil {
+		t.Fatalf("without limit, continue or detail the names are listed: %v", err)
+	}
+	sort.Strings(names)
+	if want := []string{"clustar-a", "kube-system"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestFindAllUsageOfManagedNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-a",
+			Labels: map[string]string{managedByLabel: managedByValue}}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-b"}},
+	)
+	container := restful.NewContainer()
+	container.Add(createNameSpacesResource(client, "clustar-", network.NewManager("default", nil, nil),
+		nil, nil, capability.NewDetector("kube-system", time.Minute, client), nil).WebService())
+
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/namespaces/usage", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var usages []usageEntity
+	if err := json.Unmarshal(recorder.Body.Bytes(), &usages); err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 1 || usages[0].Namespace != "clustar-a" {
+		t.Errorf("usages = %+v, want the one of clustar-a", usages)
+	}
+}
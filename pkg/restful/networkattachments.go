@@ -0,0 +1,141 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var errMultusUnavailable = errors.New("multus is not installed in the cluster")
+
+type networkAttachmentEntity struct {
+	Name      string `json:"name" description:"name of the network attachment definition"`
+	Namespace string `json:"namespace" description:"namespace of the network attachment definition"`
+	Source    string `json:"source" description:"name of the definition in the source namespace it was copied from"`
+	Config    string `json:"config" description:"CNI configuration of the definition"`
+	// customised copies are not updated when their source changes
+	Customised bool `json:"customised" description:"whether the copy has been changed inside the namespace"`
+}
+
+func newNetworkAttachmentEntity(definition unstructured.Unstructured) networkAttachmentEntity {
+	return networkAttachmentEntity{
+		Name:       definition.GetName(),
+		Namespace:  definition.GetNamespace(),
+		Source:     definition.GetLabels()[network.SourceLabel],
+		Config:     network.Config(definition),
+		Customised: network.IsCustomised(definition),
+	}
+}
+
+// GET http://localhost:8080/namespaces/clustar-{ns}/network-attachments
+//
+func (nsr NameSpacesResource) findAllNetworkAttachments(request *restful.Request, response *restful.Response) {
+	if !nsr.networks.IsAvailable() {
+		response.WriteError(http.StatusServiceUnavailable, errMultusUnavailable)
+		return
+	}
+	nameOfSpace := request.PathParameter("namespace")
+
+	definitions, err := nsr.networks.ListManaged(nameOfSpace)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	list := []networkAttachmentEntity{}
+	for _, each := range definitions {
+		list = append(list, newNetworkAttachmentEntity(each))
+	}
+	response.WriteEntity(list)
+}
+
+// POST http://localhost:8080/namespaces/clustar-{ns}/network-attachments
+//
+func (nsr NameSpacesResource) addNetworkAttachments(request *restful.Request, response *restful.Response) {
+	if !nsr.networks.IsAvailable() {
+		response.WriteError(http.StatusServiceUnavailable, errMultusUnavailable)
+		return
+	}
+	nameOfSpace := request.PathParameter("namespace")
+	if !strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", nameOfSpace)))
+		return
+	}
+
+	selection := &network.Selection{}
+	if err := request.ReadEntity(selection); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if err := selection.Validate(); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	definitions, err := nsr.networks.Select(selection)
+	if err != nil {
+		response.WriteError(statusOfNetworkError(err), err)
+		return
+	}
+	results, err := nsr.networks.Copy(nameOfSpace, definitions)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if results == nil {
+		results = []network.SyncResult{}
+	}
+	response.WriteEntity(results)
+}
+
+// DELETE http://localhost:8080/namespaces/clustar-{ns}/network-attachments/sriov-conf
+//
+func (nsr NameSpacesResource) removeNetworkAttachment(request *restful.Request, response *restful.Response) {
+	if !nsr.networks.IsAvailable() {
+		response.WriteError(http.StatusServiceUnavailable, errMultusUnavailable)
+		return
+	}
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAttachment := request.PathParameter("attachment")
+	if !strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot remove through service!", nameOfSpace)))
+		return
+	}
+
+	err := nsr.networks.Remove(nameOfSpace, nameOfAttachment)
+	if err != nil {
+		response.WriteError(statusOfNetworkError(err), err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
+
+// POST http://localhost:8080/namespaces/clustar-{ns}/network-attachments/sriov-conf/sync?force=true
+//
+func (nsr NameSpacesResource) syncNetworkAttachment(request *restful.Request, response *restful.Response) {
+	if !nsr.networks.IsAvailable() {
+		response.WriteError(http.StatusServiceUnavailable, errMultusUnavailable)
+		return
+	}
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAttachment := request.PathParameter("attachment")
+	force := request.QueryParameter("force") == "true"
+	if !strings.HasPrefix(nameOfSpace, nsr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", nameOfSpace)))
+		return
+	}
+
+	result, err := nsr.networks.Sync(nameOfSpace, nameOfAttachment, force)
+	if err != nil {
+		response.WriteError(statusOfNetworkError(err), err)
+		return
+	}
+	response.WriteEntity(result)
+}
@@ -0,0 +1,96 @@
+package restful
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+type permissionEntity struct {
+	Subject      string   `json:"subject" description:"subject as Kind/Namespace/Name or Kind/Name"`
+	APIGroup     string   `json:"apiGroup" description:"api group of the resource"`
+	Resource     string   `json:"resource" description:"resource, * stands for all resources"`
+	ResourceName string   `json:"resourceName,omitempty" description:"name of the resource if the permission is restricted to it"`
+	Verbs        []string `json:"verbs" description:"verbs the subject may use on the resource"`
+	Bindings     []string `json:"bindings" description:"bindings which grant the verbs"`
+}
+
+type permissionMatrixEntity struct {
+	Namespace   string             `json:"namespace" description:"name of the namespace"`
+	Permissions []permissionEntity `json:"permissions" description:"rows of the subject x resource x verb matrix"`
+}
+
+// GET http://localhost:8080/namespaces/clustar-{ns}/permissions?subject=ServiceAccount/clustar-{ns}/default&format=csv
+//
+func (nsr NameSpacesResource) findPermissions(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	subject := request.QueryParameter("subject")
+	resource := request.QueryParameter("resource")
+	verb := request.QueryParameter("verb")
+	format := request.QueryParameter("format")
+	if format != "" && format != "json" && format != "csv" {
+		response.WriteError(http.StatusBadRequest, errors.New(fmt.Sprintf("format: %s must be json or csv", format)))
+		return
+	}
+
+	entries, err := rbac.EffectivePermissions(nsr.k8sClient, nameOfSpace)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+
+	matrix := permissionMatrixEntity{Namespace: nameOfSpace, Permissions: []permissionEntity{}}
+	for _, entry := range entries {
+		if subject != "" && rbac.SubjectString(entry.Subject) != subject {
+			continue
+		}
+		if resource != "" && entry.Resource != resource && entry.Resource != rbacv1.ResourceAll {
+			continue
+		}
+		if verb != "" && !containsString(entry.Verbs, verb) && !containsString(entry.Verbs, rbacv1.VerbAll) {
+			continue
+		}
+		matrix.Permissions = append(matrix.Permissions, permissionEntity{
+			Subject:      rbac.SubjectString(entry.Subject),
+			APIGroup:     entry.APIGroup,
+			Resource:     entry.Resource,
+			ResourceName: entry.ResourceName,
+			Verbs:        entry.Verbs,
+			Bindings:     entry.Bindings,
+		})
+	}
+
+	if format != "csv" {
+		response.WriteEntity(matrix)
+		return
+	}
+
+	buffer := &bytes.Buffer{}
+	writer := csv.NewWriter(buffer)
+	writer.Write([]string{"subject", "apiGroup", "resource", "resourceName", "verb", "bindings"})
+	for _, each := range matrix.Permissions {
+		for _, one := range each.Verbs {
+			writer.Write([]string{each.Subject, each.APIGroup, each.Resource, each.ResourceName, one,
+				strings.Join(each.Bindings, ";")})
+		}
+	}
+	writer.Flush()
+	response.AddHeader("Content-Type", "text/csv")
+	response.Write(buffer.Bytes())
+}
+
+func containsString(values []string, value string) bool {
+	for _, each := range values {
+		if each == value {
+			return true
+		}
+	}
+	return false
+}
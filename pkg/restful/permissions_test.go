@@ -0,0 +1,113 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFindPermissions(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&rbacV1.Role{
+			ObjectMeta: metaV1.ObjectMeta{Name: "pod-reader", Namespace: "clustar-a"},
+			Rules:      []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}},
+		},
+		&rbacV1.RoleBinding{
+			ObjectMeta: metaV1.ObjectMeta{Name: "read-pods", Namespace: "clustar-a"},
+			Subjects: []rbacV1.Subject{
+				{Kind: rbacV1.UserKind, APIGroup: rbacV1.GroupName, Name: "alice"},
+				{Kind: rbacV1.GroupKind, APIGroup: rbacV1.GroupName, Name: "readers"},
+			},
+			RoleRef: rbacV1.RoleRef{Kind: "Role", Name: "pod-reader"},
+		},
+	)
+	container := restful.NewContainer()
+	container.Add(createNameSpacesResource(client, "clustar-", network.NewManager("default", nil, nil),
+		nil, nil, nil, nil).WebService())
+
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+		subjects    []string
+		csv         string
+	}{
+		{
+			name:        "json",
+			status:      http.StatusOK,
+			contentType: restful.MIME_JSON,
+			subjects:    []string{"Group/readers", "User/alice"},
+		},
+		{
+			name:        "subject filter",
+			query:       "subject=User/alice",
+			status:      http.StatusOK,
+			contentType: restful.MIME_JSON,
+			subjects:    []string{"User/alice"},
+		},
+		{
+			name:        "verb filter without match",
+			query:       "verb=delete",
+			status:      http.StatusOK,
+			contentType: restful.MIME_JSON,
+			subjects:    []string{},
+		},
+		{
+			name:        "csv",
+			query:       "subject=Group/readers&format=csv",
+			status:      http.StatusOK,
+			contentType: "text/csv",
+			csv: "subject,apiGroup,resource,resourceName,verb,bindings\n" +
+				"Group/readers,,pods,,get,RoleBinding/read-pods\n" +
+				"Group/readers,,pods,,list,RoleBinding/read-pods\n",
+		},
+		{
+			name:   "unknown format",
+			query:  "format=xml",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+				"/namespaces/clustar-a/permissions?"+test.query, nil))
+			if recorder.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, test.status, recorder.Body.String())
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, test.contentType) {
+				t.Errorf("Content-Type = %s, want %s", contentType, test.contentType)
+			}
+			if test.contentType == "text/csv" {
+				if recorder.Body.String() != test.csv {
+					t.Errorf("csv = %q, want %q", recorder.Body.String(), test.csv)
+				}
+				return
+			}
+			matrix := permissionMatrixEntity{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &matrix); err != nil {
+				t.Fatal(err)
+			}
+			subjects := []string{}
+			for _, each := range matrix.Permissions {
+				subjects = append(subjects, each.Subject)
+			}
+			if !reflect.DeepEqual(subjects, test.subjects) {
+				t.Errorf("subjects = %v, want %v", subjects, test.subjects)
+			}
+		})
+	}
+}
@@ -1,40 +1,86 @@
 package restful
 
 import (
+	"errors"
+	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
+	"github.com/golang/glog"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
 	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type persistentVolumeResource struct {
 	k8sClient                kubernetes.Interface
 	selfDefineResourcePrefix string
+	classes                  *storage.Classes
+	budgets                  *storage.Budgets
 }
 
 type persistentVolumeAction struct {
-	PvName       string `json:"pvName" description:"name of the pv"`
-	PvcName      string `json:"pvcName" description:"name of the pvc"`
-	NameSpace    string `json:"namespace" description:"name of the namespace"`
-	NfsPath      string `json:"nfsPath" description:"path of the nfs"`
-	NfsIp        string `json:"nfsIp" description:"ip of the nfs"`
-	StorageClass string `json:"storageClass" description:"class of the storage"`
-	Storage      string `json:"storage" description:"quantity of the storage"`
-	AccessMode   string `json:"accessMode" description:"mode of the access"`
+	PvName       string          `json:"pvName" description:"name of the pv"`
+	PvcName      string          `json:"pvcName" description:"name of the pvc"`
+	NameSpace    string          `json:"namespace" description:"name of the namespace"`
+	NfsPath      string          `json:"nfsPath" description:"path of the nfs, when no source is given"`
+	NfsIp        string          `json:"nfsIp" description:"ip of the nfs, when no source is given"`
+	StorageClass string          `json:"storageClass" description:"class of the storage"`
+	Storage      string          `json:"storage" description:"quantity of the storage"`
+	AccessMode   string          `json:"accessMode" description:"mode of the access"`
+	Source       *storage.Source `json:"source,omitempty" description:"backend of the volume and its parameters"`
+}
+
+// accessModes lists the modes a pv and pvc may be created with
+var accessModes = map[coreV1.PersistentVolumeAccessMode]bool{
+	coreV1.ReadWriteOnce: true,
+	coreV1.ReadOnlyMany:  true,
+	coreV1.ReadWriteMany: true,
+}
+
+// isDynamic tells if the action names no source at all, its volume is then provisioned from the storage class
+func (action *persistentVolumeAction) isDynamic() bool {
+	return action.Source == nil && action.NfsIp == "" && action.NfsPath == ""
+}
+
+// volumeSource returns the source of the action, requests made before the backends were pluggable
+// only name an nfs export through NfsIp and NfsPath
+func (action *persistentVolumeAction) volumeSource() *storage.Source {
+	if action.Source != nil {
+		return action.Source
+	}
+	return &storage.Source{
+		Type: storage.NFS,
+		NFS:  &storage.NFSParameters{Server: action.NfsIp, Path: action.NfsPath},
+	}
+}
+
+type storageBackendEntity struct {
+	Type        string              `json:"type" description:"type of the backend, used as the type of a volume source"`
+	Description string              `json:"description" description:"what the backend provides"`
+	Parameters  []storage.Parameter `json:"parameters" description:"parameters of the backend"`
 }
 
 type persistentVolumeEntity struct {
-	pv  coreV1.PersistentVolume      `json:pv`
-	pvc coreV1.PersistentVolumeClaim `json:pvc`
+	PV     *coreV1.PersistentVolume      `json:"pv,omitempty" description:"the pv, missing until one is bound to a provisioned pvc"`
+	PVC    *coreV1.PersistentVolumeClaim `json:"pvc" description:"the pvc"`
+	Bound  bool                          `json:"bound" description:"whether the pvc has been bound to the pv"`
+	Reason string                        `json:"reason,omitempty" description:"why the pvc has not been bound"`
 }
 
-func createPersistVolumeResource(k8sClient kubernetes.Interface, prefix string) (resource *persistentVolumeResource) {
+func createPersistVolumeResource(k8sClient kubernetes.Interface, prefix string, classes *storage.Classes,
+	budgets *storage.Budgets) (resource *persistentVolumeResource) {
 	resource = &persistentVolumeResource{
 		k8sClient:                k8sClient,
 		selfDefineResourcePrefix: prefix,
+		classes:                  classes,
+		budgets:                  budgets,
 	}
 	return
 }
@@ -48,34 +94,164 @@ func (pvr persistentVolumeResource) WebService() *restful.WebService {
 	tags := []string{"pv"}
 
 	ws.Route(ws.POST("/").To(pvr.createPersistentVolumeClaim).
-		Doc("create shared pv").
+		Doc("create shared pv together with its pvc, or only a pvc provisioned from the storage class when no source is given").
+		Param(ws.QueryParameter("timeout", "seconds to wait for the pvc to be bound, 60 by default").DataType("integer")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(persistentVolumeAction{}). // on the response
-		Returns(200, "OK", nil).
+		Writes(persistentVolumeEntity{}).
+		Returns(200, "OK", persistentVolumeEntity{}).
+		Returns(400, "Invalid volume source", nil).
+		Returns(403, "Storage class not allowed or storage budget exceeded", nil).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Name taken by another pv or pvc", nil).
+		Returns(504, "PVC not bound in time", persistentVolumeEntity{}))
+
+	ws.Route(ws.GET("/backends").To(pvr.findAllBackends).
+		// docs
+		Doc("get the storage backends a pv can be created on").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]storageBackendEntity{}).
+		Returns(200, "OK", []storageBackendEntity{}))
+
+	ws.Route(ws.GET("/").To(pvr.findAllVolumes).
+		// docs
+		Doc("get all pv together with their pvc").
+		Param(ws.QueryParameter("namespace", "only the pvc inside the namespace").DataType("string")).
+		Param(ws.QueryParameter("managed", "only the pv created by the service").DataType("boolean")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]volumeEntity{}).
+		Returns(200, "OK", []volumeEntity{}))
+
+	ws.Route(ws.GET("/volumes/{volume}").To(pvr.getVolume).
+		// docs
+		Doc("get a pv together with its pvc").
+		Param(ws.PathParameter("volume", "name of the pv").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(volumeEntity{}).
+		Returns(200, "OK", volumeEntity{}).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{namespace}/{claim}").To(pvr.getClaimVolume).
+		// docs
+		Doc("get a pvc together with its pv").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Param(ws.PathParameter("claim", "name of the pvc").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(volumeEntity{}).
+		Returns(200, "OK", volumeEntity{}).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(ws.DELETE("/{namespace}/{claim}").To(pvr.removeClaimVolume).
+		// docs
+		Doc("delete a pvc, wait until its pv is released and delete the pv if the service created it, "+
+			"any other pv is left to its reclaim policy; dataset claims are detached through the datasets endpoint").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Param(ws.PathParameter("claim", "name of the pvc").DataType("string")).
+		Param(ws.QueryParameter("timeout", "seconds to wait for the pv to be released, 60 by default").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(200, "OK", Result{}).
+		Returns(400, "Claim of a dataset", nil).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Mounted by running pods", volumeInUseEntity{}).
+		Returns(504, "PV not released in time", nil))
+
 	return ws
 }
 
+// GET http://localhost:8080/pv/backends
+//
+func (pvr persistentVolumeResource) findAllBackends(request *restful.Request, response *restful.Response) {
+	list := []storageBackendEntity{}
+	for _, backend := range storage.Backends() {
+		list = append(list, storageBackendEntity{
+			Type:        backend.Type(),
+			Description: backend.Description(),
+			Parameters:  backend.Parameters(),
+		})
+	}
+	response.WriteEntity(list)
+}
+
+// POST http://localhost:8080/pv/?timeout=60
+//
 func (pvr persistentVolumeResource) createPersistentVolumeClaim(request *restful.Request, response *restful.Response) {
 
 	action := &persistentVolumeAction{}
 	if err := request.ReadEntity(action); err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if !strings.HasPrefix(action.NameSpace, pvr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", action.NameSpace)))
+		return
+	}
+	if !accessModes[coreV1.PersistentVolumeAccessMode(action.AccessMode)] {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("invalid access mode: %s, must be one of %s, %s or %s", action.AccessMode,
+				coreV1.ReadWriteOnce, coreV1.ReadOnlyMany, coreV1.ReadWriteMany)))
 		return
 	}
 	quantity, err := resource.ParseQuantity(action.Storage)
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		response.WriteError(http.StatusBadRequest, err)
 		return
 	}
-	_, err = pvr.k8sClient.CoreV1().PersistentVolumes().Get(action.PvName, metaV1.GetOptions{})
-	if err == nil {
-		response.Write([]byte("{\"status\":\"success\"}"))
+	timeout, err := timeoutParameter(request, defaultBindTimeout)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
 	}
 
+	// the pair is named by the client, asking for an existing pair again returns it instead of failing
+	existingClaim, err := pvr.k8sClient.CoreV1().PersistentVolumeClaims(action.NameSpace).Get(action.PvcName, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		existingClaim, err = nil, nil
+	}
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if existingClaim == nil {
+		if err := pvr.budgets.Check(action.NameSpace, quantity); err != nil {
+			response.WriteError(statusOfStorageError(err), err)
+			return
+		}
+	}
+
+	if action.isDynamic() {
+		pvr.provisionPersistentVolumeClaim(action, quantity, existingClaim, timeout, response)
+		return
+	}
+
+	if existingClaim != nil && (existingClaim.Labels[managedByLabel] != managedByValue || existingClaim.Spec.VolumeName != action.PvName) {
+		response.WriteError(http.StatusConflict, errors.New(
+			fmt.Sprintf("pvc %s/%s already exists and is not bound to pv %s", action.NameSpace, action.PvcName, action.PvName)))
+		return
+	}
+	existingVolume, err := pvr.k8sClient.CoreV1().PersistentVolumes().Get(action.PvName, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		existingVolume, err = nil, nil
+	}
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if existingVolume != nil && (existingVolume.Labels[managedByLabel] != managedByValue || existingVolume.Spec.ClaimRef == nil ||
+		existingVolume.Spec.ClaimRef.Namespace != action.NameSpace || existingVolume.Spec.ClaimRef.Name != action.PvcName) {
+		response.WriteError(http.StatusConflict, errors.New(
+			fmt.Sprintf("pv %s already exists and is not reserved for pvc %s/%s", action.PvName, action.NameSpace, action.PvcName)))
+		return
+	}
+
+	source := action.volumeSource()
+	if err := source.Validate(action.NameSpace); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	volumeSource, nodeAffinity := source.VolumeSource(action.NameSpace)
+
 	accessMode := coreV1.PersistentVolumeAccessMode(action.AccessMode)
-	nfs := coreV1.NFSVolumeSource{Server: action.NfsIp, Path: action.NfsPath}
 
 	persistentVolumeTemp := &coreV1.PersistentVolume{
 		TypeMeta: metaV1.TypeMeta{
@@ -83,14 +259,23 @@ func (pvr persistentVolumeResource) createPersistentVolumeClaim(request *restful
 			Kind:       "PersistentVolume",
 		},
 		ObjectMeta: metaV1.ObjectMeta{
-			Name: action.PvName,
+			Name:   action.PvName,
+			Labels: map[string]string{managedByLabel: managedByValue},
 		},
 		Spec: coreV1.PersistentVolumeSpec{
 			AccessModes:                   []coreV1.PersistentVolumeAccessMode{accessMode},
 			Capacity:                      coreV1.ResourceList{coreV1.ResourceStorage: quantity},
-			PersistentVolumeSource:        coreV1.PersistentVolumeSource{NFS: &nfs},
+			PersistentVolumeSource:        volumeSource,
+			NodeAffinity:                  nodeAffinity,
 			PersistentVolumeReclaimPolicy: "Delete",
 			StorageClassName:              action.StorageClass,
+			// reserved for the pvc, no other claim can take the pv before the pair is bound
+			ClaimRef: &coreV1.ObjectReference{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+				Namespace:  action.NameSpace,
+				Name:       action.PvcName,
+			},
 		},
 	}
 
@@ -102,6 +287,7 @@ func (pvr persistentVolumeResource) createPersistentVolumeClaim(request *restful
 		ObjectMeta: metaV1.ObjectMeta{
 			Name:      action.PvcName,
 			Namespace: action.NameSpace,
+			Labels:    map[string]string{managedByLabel: managedByValue},
 		},
 		Spec: coreV1.PersistentVolumeClaimSpec{
 			AccessModes:      []coreV1.PersistentVolumeAccessMode{accessMode},
@@ -111,17 +297,130 @@ func (pvr persistentVolumeResource) createPersistentVolumeClaim(request *restful
 		},
 	}
 
-	persistentVolumeTemp, err = pvr.k8sClient.CoreV1().PersistentVolumes().Create(persistentVolumeTemp)
+	if existingVolume == nil {
+		_, err = pvr.k8sClient.CoreV1().PersistentVolumes().Create(persistentVolumeTemp)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+	}
+	if existingClaim == nil {
+		_, err = pvr.k8sClient.CoreV1().PersistentVolumeClaims(action.NameSpace).Create(persistentVolumeClaimTemp)
+		if err != nil {
+			// the pv is only kept together with its pvc
+			if existingVolume == nil {
+				rollbackErr := pvr.k8sClient.CoreV1().PersistentVolumes().Delete(action.PvName, &metaV1.DeleteOptions{})
+				if rollbackErr != nil && !k8sError.IsNotFound(rollbackErr) {
+					glog.Errorf("rolling back pv %s: %s", action.PvName, rollbackErr)
+				}
+			}
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+	}
+	pvr.writeBoundVolume(action.NameSpace, action.PvcName, timeout, response)
+}
+
+// provisionPersistentVolumeClaim creates only the pvc of the action, its pv comes from the provisioner of the class
+func (pvr persistentVolumeResource) provisionPersistentVolumeClaim(action *persistentVolumeAction, quantity resource.Quantity,
+	existingClaim *coreV1.PersistentVolumeClaim, timeout time.Duration, response *restful.Response) {
+	if action.StorageClass == "" {
+		response.WriteError(http.StatusBadRequest, errors.New("storageClass is required when no volume source is given"))
+		return
+	}
+	class, err := pvr.classes.Get(action.StorageClass)
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		response.WriteError(statusOfStorageError(err), err)
+		return
+	}
+
+	if existingClaim != nil {
+		if existingClaim.Labels[managedByLabel] != managedByValue || existingClaim.Spec.StorageClassName == nil ||
+			*existingClaim.Spec.StorageClassName != action.StorageClass {
+			response.WriteError(http.StatusConflict, errors.New(
+				fmt.Sprintf("pvc %s/%s already exists and is not provisioned from %s", action.NameSpace, action.PvcName, action.StorageClass)))
+			return
+		}
+	} else {
+		persistentVolumeClaimTemp := &coreV1.PersistentVolumeClaim{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      action.PvcName,
+				Namespace: action.NameSpace,
+				Labels:    map[string]string{managedByLabel: managedByValue},
+			},
+			Spec: coreV1.PersistentVolumeClaimSpec{
+				AccessModes:      []coreV1.PersistentVolumeAccessMode{coreV1.PersistentVolumeAccessMode(action.AccessMode)},
+				Resources:        coreV1.ResourceRequirements{Requests: coreV1.ResourceList{coreV1.ResourceStorage: quantity}},
+				StorageClassName: &action.StorageClass,
+			},
+		}
+		_, err = pvr.k8sClient.CoreV1().PersistentVolumeClaims(action.NameSpace).Create(persistentVolumeClaimTemp)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+	}
+
+	// such claims stay pending until the first pod using them is scheduled
+	if !storage.BindsImmediately(class) {
+		pvc, err := pvr.k8sClient.CoreV1().PersistentVolumeClaims(action.NameSpace).Get(action.PvcName, metaV1.GetOptions{})
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		response.WriteEntity(persistentVolumeEntity{
+			PVC:    pvc,
+			Reason: fmt.Sprintf("storage class %s binds the pvc once a pod using it is scheduled", class.Name),
+		})
+		return
+	}
+	pvr.writeBoundVolume(action.NameSpace, action.PvcName, timeout, response)
+}
+
+// writeBoundVolume waits until the pvc is bound and writes it together with its pv,
+// when it is still pending after timeout the reason is reported with 504
+func (pvr persistentVolumeResource) writeBoundVolume(namespace, claim string, timeout time.Duration, response *restful.Response) {
+	pvc, pv, err := waitForClaimBound(pvr.k8sClient, namespace, claim, timeout)
+	if err == wait.ErrWaitTimeout && pvc != nil {
+		entity := persistentVolumeEntity{PVC: pvc}
+		if pvc.Spec.VolumeName != "" {
+			entity.PV, _ = pvr.k8sClient.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metaV1.GetOptions{})
+		}
+		entity.Reason = pvr.pendingReason(pvc, entity.PV, timeout)
+		response.WriteHeaderAndEntity(http.StatusGatewayTimeout, entity)
 		return
 	}
-	persistentVolumeClaimTemp, err = pvr.k8sClient.CoreV1().PersistentVolumeClaims(action.NameSpace).Create(persistentVolumeClaimTemp)
 	if err != nil {
-		response.WriteError(http.StatusInternalServerError, err)
+		response.WriteError(statusOfError(err), err)
 		return
 	}
-	response.WriteEntity(persistentVolumeEntity{*persistentVolumeTemp, *persistentVolumeClaimTemp})
-	return
+	response.WriteEntity(persistentVolumeEntity{PV: pv, PVC: pvc, Bound: true})
+}
 
+// pendingReason explains why a pvc is not bound, from the pv or the last warning recorded for the pvc
+func (pvr persistentVolumeResource) pendingReason(pvc *coreV1.PersistentVolumeClaim, pv *coreV1.PersistentVolume, timeout time.Duration) string {
+	reason := fmt.Sprintf("pvc %s/%s is still %s after %s", pvc.Namespace, pvc.Name, pvc.Status.Phase, timeout)
+	if pv != nil && pv.Status.Message != "" {
+		return fmt.Sprintf("%s: pv %s is %s: %s", reason, pv.Name, pv.Status.Phase, pv.Status.Message)
+	}
+	events, err := pvr.k8sClient.CoreV1().Events(pvc.Namespace).List(metaV1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=PersistentVolumeClaim,involvedObject.name=%s", pvc.Name),
+	})
+	if err != nil {
+		return reason
+	}
+	var last *coreV1.Event
+	for i := range events.Items {
+		event := &events.Items[i]
+		if event.Type != coreV1.EventTypeWarning {
+			continue
+		}
+		if last == nil || last.LastTimestamp.Before(&event.LastTimestamp) {
+			last = event
+		}
+	}
+	if last != nil {
+		return fmt.Sprintf("%s: %s: %s", reason, last.Reason, last.Message)
+	}
+	return reason
 }
@@ -0,0 +1,368 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	"github.com/golang/glog"
+	"github.com/starcloud-ai/kubeconfig/pkg/network"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+type ReconcileResource struct {
+	k8sClient                kubernetes.Interface
+	selfDefineResourcePrefix string
+	registry                 *rbac.Registry
+	budgets                  *storage.Budgets
+	networks                 *network.Manager
+
+	lock     sync.Mutex
+	progress *reconcileProgress
+}
+
+type reconcileProgress struct {
+	Running   bool                    `json:"running" description:"whether the rollout is still running"`
+	StartTime time.Time               `json:"startTime" description:"when the rollout started"`
+	EndTime   *time.Time              `json:"endTime,omitempty" description:"when the rollout finished"`
+	Total     int                     `json:"total" description:"number of tenant namespaces"`
+	Done      int                     `json:"done" description:"number of tenant namespaces reconciled so far"`
+	Failed    int                     `json:"failed" description:"number of tenant namespaces with errors"`
+	Changed   int                     `json:"changed" description:"number of objects changed so far"`
+	Tenants   []tenantReconcileResult `json:"tenants" description:"result of every reconciled tenant namespace"`
+}
+
+type tillerMigrationEntity struct {
+	HelmMode string   `json:"helmMode" description:"helm mode of the service"`
+	DryRun   bool     `json:"dryRun" description:"whether the stale bindings were only reported"`
+	Stale    []string `json:"stale" description:"stale tiller role bindings"`
+	Kept     int      `json:"kept" description:"number of tiller role bindings still in use"`
+}
+
+type tenantReconcileResult struct {
+	Namespace string   `json:"namespace" description:"name of the tenant namespace"`
+	Accounts  int      `json:"accounts" description:"number of managed accounts in the namespace"`
+	Grants    int      `json:"grants" description:"number of bindings granted to accounts of other namespaces"`
+	Changes   []string `json:"changes,omitempty" description:"objects changed in the namespace"`
+	Errors    []string `json:"errors,omitempty" description:"errors met in the namespace"`
+}
+
+func createReconcileResource(k8sClient kubernetes.Interface, prefix string,
+	registry *rbac.Registry, budgets *storage.Budgets, networks *network.Manager) (resource *ReconcileResource) {
+	resource = &ReconcileResource{
+		k8sClient:                k8sClient,
+		selfDefineResourcePrefix: prefix,
+		registry:                 registry,
+		budgets:                  budgets,
+		networks:                 networks,
+	}
+	return
+}
+
+func (rcr *ReconcileResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/reconcile").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"reconcile"}
+
+	ws.Route(ws.POST("/").To(rcr.startReconcile).
+		// docs
+		Doc("roll the current role templates out to the roles and bindings of all tenants, and bring their labels, storage quota and network attachment copies up to date").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(reconcileProgress{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(409, "Conflict", nil))
+
+	ws.Route(ws.GET("/").To(rcr.getReconcile).
+		// docs
+		Doc("report the progress of the last rollout").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(reconcileProgress{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/tiller-bindings").To(rcr.migrateTillerBindings).
+		// docs
+		Doc("remove tiller role bindings which are no longer needed, because helm 2 is not used or the account is gone").
+		Param(ws.QueryParameter("dryRun", "only report the stale bindings").DataType("boolean").DefaultValue("false")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(tillerMigrationEntity{}). // on the response
+		Returns(200, "OK", nil))
+
+	return ws
+}
+
+// POST http://localhost:8080/reconcile
+//
+func (rcr *ReconcileResource) startReconcile(request *restful.Request, response *restful.Response) {
+	namespaces, err := rcr.k8sClient.CoreV1().Namespaces().List(metaV1.ListOptions{})
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	var tenants []string
+	for _, each := range namespaces.Items {
+		if strings.HasPrefix(each.Name, rcr.selfDefineResourcePrefix) {
+			tenants = append(tenants, each.Name)
+		}
+	}
+
+	rcr.lock.Lock()
+	defer rcr.lock.Unlock()
+	if rcr.progress != nil && rcr.progress.Running {
+		response.WriteError(http.StatusConflict, errors.New("a rollout is already running"))
+		return
+	}
+	rcr.progress = &reconcileProgress{Running: true, StartTime: time.Now(), Total: len(tenants)}
+	go rcr.rollout(tenants)
+
+	response.WriteEntity(rcr.progress)
+}
+
+// GET http://localhost:8080/reconcile
+//
+func (rcr *ReconcileResource) getReconcile(request *restful.Request, response *restful.Response) {
+	rcr.lock.Lock()
+	defer rcr.lock.Unlock()
+	if rcr.progress == nil {
+		response.WriteError(http.StatusNotFound, errors.New("no rollout has been started"))
+		return
+	}
+	response.WriteEntity(rcr.progress)
+}
+
+func (rcr *ReconcileResource) rollout(tenants []string) {
+	for _, tenant := range tenants {
+		result := rcr.reconcileTenant(tenant)
+		if len(result.Errors) > 0 {
+			glog.Errorf("reconcile %s: %s", tenant, strings.Join(result.Errors, "; "))
+		}
+
+		rcr.lock.Lock()
+		rcr.progress.Done++
+		rcr.progress.Changed += len(result.Changes)
+		if len(result.Errors) > 0 {
+			rcr.progress.Failed++
+		}
+		rcr.progress.Tenants = append(rcr.progress.Tenants, result)
+		rcr.lock.Unlock()
+	}
+
+	rcr.lock.Lock()
+	now := time.Now()
+	rcr.progress.Running = false
+	rcr.progress.EndTime = &now
+	rcr.lock.Unlock()
+}
+
+// reconcileTenant reconciles the accounts of the namespace and the grants inside it
+func (rcr *ReconcileResource) reconcileTenant(nameOfSpace string) (result tenantReconcileResult) {
+	result.Namespace = nameOfSpace
+
+	// tenants created before namespaces were labeled are listed as managed only once labeled
+	if changed, err := rcr.labelManagedNamespace(nameOfSpace); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("label %s: %s", managedByLabel, err))
+	} else if changed {
+		result.Changes = append(result.Changes, fmt.Sprintf("label %s", managedByLabel))
+	}
+
+	// tenants provisioned before the budgets were enforced by a quota get one here
+	if changed, err := rcr.budgets.SyncQuota(nameOfSpace); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("storage quota: %s", err))
+	} else if changed {
+		result.Changes = append(result.Changes, "quota storage-budget")
+	}
+
+	// network attachments copied before copies were labeled are only propagated to once adopted
+	if rcr.networks.IsAvailable() {
+		adopted, err := rcr.networks.Adopt(nameOfSpace)
+		for _, each := range adopted {
+			result.Changes = append(result.Changes, fmt.Sprintf("network attachment %s", each.Name))
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("network attachments: %s", err))
+		}
+	}
+
+	serviceAccounts, err := rcr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).List(metaV1.ListOptions{})
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	bindings, err := rcr.k8sClient.RbacV1().RoleBindings(nameOfSpace).List(metaV1.ListOptions{})
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+
+	bound := map[string]bool{}
+	for _, each := range bindings.Items {
+		if accountNamespace, accountName, ok := rbac.ParseRoleBindingName(each.Name); ok && accountNamespace == nameOfSpace {
+			bound[accountName] = true
+		}
+	}
+
+	for i := range serviceAccounts.Items {
+		serviceAccount := &serviceAccounts.Items[i]
+		// accounts which were neither provisioned nor bound by the service are left alone
+		if _, ok := serviceAccount.Annotations[rbac.ProfileAnnotation]; !ok && !bound[serviceAccount.Name] {
+			continue
+		}
+		result.Accounts++
+
+		profiles, customRole, customClusterRole := rbac.ProfilesOfServiceAccount(serviceAccount)
+		roles, err := rcr.registry.AccountRoles(nameOfSpace, profiles, customRole, customClusterRole)
+		if err == nil {
+			var changes []string
+			changes, err = rbac.ReconcileAccount(roles, nameOfSpace, serviceAccount.Name, rbac.SubjectsOfServiceAccount(serviceAccount))
+			result.Changes = append(result.Changes, changes...)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("account %s: %s", serviceAccount.Name, err))
+		}
+	}
+
+	for _, each := range bindings.Items {
+		accountNamespace, accountName, ok := rbac.ParseRoleBindingName(each.Name)
+		if !ok || accountNamespace == nameOfSpace {
+			continue
+		}
+		result.Grants++
+
+		profile := rbac.ProfileOfRoleRef(nameOfSpace, each.RoleRef)
+		var customRole, customClusterRole string
+		if each.RoleRef.Kind == "ClusterRole" {
+			customClusterRole = each.RoleRef.Name
+		} else {
+			customRole = each.RoleRef.Name
+		}
+		roles, err := rcr.registry.GrantRoles(nameOfSpace, profile, customRole, customClusterRole)
+		var serviceAccount *coreV1.ServiceAccount
+		if err == nil {
+			// the subjects of a grant are recorded on the account in its own namespace
+			serviceAccount, err = rcr.k8sClient.CoreV1().ServiceAccounts(accountNamespace).Get(accountName, metaV1.GetOptions{})
+		}
+		if err == nil {
+			var changes []string
+			changes, err = rbac.ReconcileAccount(roles, accountNamespace, accountName, rbac.SubjectsOfServiceAccount(serviceAccount))
+			result.Changes = append(result.Changes, changes...)
+		}
+		if err != nil && !k8sError.IsNotFound(err) {
+			result.Errors = append(result.Errors, fmt.Sprintf("grant %s: %s", each.Name, err))
+		}
+	}
+	return
+}
+
+// labelManagedNamespaces labels the tenant namespaces created before namespaces were labeled, it runs once
+// at startup so that they are listed as managed without waiting for a rollout
+func (rcr *ReconcileResource) labelManagedNamespaces() {
+	namespaces, err := rcr.k8sClient.CoreV1().Namespaces().List(metaV1.ListOptions{})
+	if err != nil {
+		glog.Errorf("label tenant namespaces: %s", err)
+		return
+	}
+	for _, each := range namespaces.Items {
+		if !strings.HasPrefix(each.Name, rcr.selfDefineResourcePrefix) || each.Labels[managedByLabel] == managedByValue {
+			continue
+		}
+		if changed, err := rcr.labelManagedNamespace(each.Name); err != nil {
+			glog.Errorf("label %s %s: %s", each.Name, managedByLabel, err)
+		} else if changed {
+			glog.Infof("labeled namespace %s %s=%s", each.Name, managedByLabel, managedByValue)
+		}
+	}
+}
+
+// labelManagedNamespace adds the label of the service to the tenant namespace and tells if it was missing
+func (rcr *ReconcileResource) labelManagedNamespace(nameOfSpace string) (bool, error) {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		namespace, err := rcr.k8sClient.CoreV1().Namespaces().Get(nameOfSpace, metaV1.GetOptions{})
+		if err != nil || namespace.Labels[managedByLabel] == managedByValue {
+			return err
+		}
+		if namespace.Labels == nil {
+			namespace.Labels = map[string]string{}
+		}
+		namespace.Labels[managedByLabel] = managedByValue
+		_, err = rcr.k8sClient.CoreV1().Namespaces().Update(namespace)
+		changed = err == nil
+		return err
+	})
+	return changed, err
+}
+
+// POST http://localhost:8080/reconcile/tiller-bindings?dryRun=true
+//
+func (rcr *ReconcileResource) migrateTillerBindings(request *restful.Request, response *restful.Response) {
+	result := tillerMigrationEntity{
+		HelmMode: rcr.registry.HelmMode,
+		DryRun:   request.QueryParameter("dryRun") == "true",
+		Stale:    []string{},
+	}
+
+	bindings, err := rcr.k8sClient.RbacV1().RoleBindings(rcr.registry.TillerNamespace).List(metaV1.ListOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	for _, each := range bindings.Items {
+		if !rbac.IsTillerBinding(rcr.registry.TillerRole, each) {
+			continue
+		}
+		stale, err := rcr.isStaleTillerBinding(each.Name)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		if !stale {
+			result.Kept++
+			continue
+		}
+		if !result.DryRun {
+			err = rcr.k8sClient.RbacV1().RoleBindings(rcr.registry.TillerNamespace).Delete(each.Name, &metaV1.DeleteOptions{})
+			if err != nil && !k8sError.IsNotFound(err) {
+				response.WriteError(statusOfError(err), err)
+				return
+			}
+		}
+		result.Stale = append(result.Stale, each.Name)
+	}
+	response.WriteEntity(result)
+}
+
+// a tiller binding is stale unless helm 2 is used and its account still has a profile which includes helm
+func (rcr *ReconcileResource) isStaleTillerBinding(bindingName string) (bool, error) {
+	if rcr.registry.HelmMode != rbac.HelmModeV2 {
+		return true, nil
+	}
+	accountNamespace, accountName, _ := rbac.ParseRoleBindingName(bindingName)
+	serviceAccount, err := rcr.k8sClient.CoreV1().ServiceAccounts(accountNamespace).Get(accountName, metaV1.GetOptions{})
+	if k8sError.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	profiles, _, _ := rbac.ProfilesOfServiceAccount(serviceAccount)
+	for _, profile := range profiles {
+		if profile == rbac.ProfileEditor || profile == rbac.ProfileAdmin {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,27 @@
+package restful
+
+import (
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLabelManagedNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-a"}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "kube-system"}},
+	)
+	createReconcileResource(client, "clustar-", nil, nil, nil).labelManagedNamespaces()
+
+	for name, managed := range map[string]bool{"clustar-a": true, "kube-system": false} {
+		namespace, err := client.CoreV1().Namespaces().Get(name, metaV1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if labeled := namespace.Labels[managedByLabel] == managedByValue; labeled != managed {
+			t.Errorf("namespace %s labeled: %v, want %v", name, labeled, managed)
+		}
+	}
+}
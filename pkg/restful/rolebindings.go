@@ -0,0 +1,260 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type RoleBindingsResource struct {
+	k8sClient                kubernetes.Interface
+	selfDefineResourcePrefix string
+	ceiling                  *rbac.Ceiling
+}
+
+type roleBindingAction struct {
+	Name     string           `json:"name" description:"name of the role binding"`
+	RoleKind string           `json:"roleKind" description:"kind of the bound role: Role or ClusterRole"`
+	RoleName string           `json:"roleName" description:"name of the bound role"`
+	Subjects []rbacv1.Subject `json:"subjects" description:"subjects the role is bound to"`
+}
+
+func createRoleBindingResource(k8sClient kubernetes.Interface, prefix string, ceiling *rbac.Ceiling) (resource *RoleBindingsResource) {
+	resource = &RoleBindingsResource{
+		k8sClient:                k8sClient,
+		selfDefineResourcePrefix: prefix,
+		ceiling:                  ceiling,
+	}
+	return
+}
+
+func (rbr RoleBindingsResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/rolebindings").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"rolebindings"}
+
+	ws.Route(ws.GET("/{namespace}").To(rbr.findAllRoleBindings).
+		// docs
+		Doc("find all role bindings under specified namespace").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]string{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{namespace}/{rolebinding}").To(rbr.getRoleBinding).
+		// docs
+		Doc("find specified role binding under specified namespace").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("rolebinding", "identifier of the role binding").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(rbacv1.RoleBinding{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/{namespace}").To(rbr.createRoleBinding).
+		// docs
+		Doc("create a role binding in specified namespace, the rules of the bound role must not exceed the ceiling policy "+
+			"and the subjects must be service accounts of the namespace or oidc users and groups of its accounts").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(roleBindingAction{}).
+		Writes(rbacv1.RoleBinding{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(409, "Conflict", nil))
+
+	ws.Route(ws.DELETE("/{namespace}/{rolebinding}").To(rbr.removeRoleBinding).
+		// docs
+		Doc("delete specified role binding in specified namespace").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Param(ws.PathParameter("rolebinding", "identifier of the role binding").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes("").
+		Returns(200, "OK", nil).
+		Returns(404, "Not Found", nil))
+
+	return ws
+}
+
+// GET http://localhost:8080/rolebindings/default
+//
+func (rbr RoleBindingsResource) findAllRoleBindings(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	bindings, err := rbr.k8sClient.RbacV1().RoleBindings(nameOfSpace).List(metaV1.ListOptions{})
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+
+	var list []string
+	for _, each := range bindings.Items {
+		list = append(list, each.Name)
+	}
+	response.WriteEntity(list)
+}
+
+// GET http://localhost:8080/rolebindings/default/{rolebinding}
+//
+func (rbr RoleBindingsResource) getRoleBinding(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfBinding := request.PathParameter("rolebinding")
+	binding, err := rbr.k8sClient.RbacV1().RoleBindings(nameOfSpace).Get(nameOfBinding, metaV1.GetOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(binding)
+}
+
+// POST http://localhost:8080/rolebindings/clustar-{ns}
+//
+func (rbr RoleBindingsResource) createRoleBinding(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+
+	action := &roleBindingAction{}
+	if err := request.ReadEntity(action); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if statenum, err := rbr.checkRoleBindingName(nameOfSpace, action.Name); err != nil {
+		response.WriteError(statenum, err)
+		return
+	}
+	if len(action.Subjects) == 0 {
+		response.WriteError(http.StatusBadRequest, errors.New("a role binding needs at least one subject"))
+		return
+	}
+
+	if action.RoleKind != "Role" && action.RoleKind != "ClusterRole" {
+		response.WriteError(http.StatusBadRequest,
+			errors.New(fmt.Sprintf("roleKind: %s must be Role or ClusterRole", action.RoleKind)))
+		return
+	}
+	subjects, statenum, err := rbr.checkSubjects(nameOfSpace, action.Subjects)
+	if err != nil {
+		response.WriteError(statenum, err)
+		return
+	}
+	if err = rbr.ceiling.CheckRoleRef(nameOfSpace, action.RoleKind, action.RoleName); err != nil {
+		response.WriteError(statusOfCheckError(err), err)
+		return
+	}
+
+	bindingTmp := &rbacv1.RoleBinding{}
+	bindingTmp.APIVersion = "v1"
+	bindingTmp.Kind = "RoleBinding"
+	bindingTmp.Name = action.Name
+	bindingTmp.Namespace = nameOfSpace
+	bindingTmp.Subjects = subjects
+	bindingTmp.RoleRef.APIGroup = rbacv1.GroupName
+	bindingTmp.RoleRef.Kind = action.RoleKind
+	bindingTmp.RoleRef.Name = action.RoleName
+	binding, err := rbr.k8sClient.RbacV1().RoleBindings(nameOfSpace).Create(bindingTmp)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(binding)
+}
+
+// DELETE http://localhost:8080/rolebindings/clustar-{ns}/{rolebinding}
+//
+func (rbr RoleBindingsResource) removeRoleBinding(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfBinding := request.PathParameter("rolebinding")
+
+	if statenum, err := rbr.checkRoleBindingName(nameOfSpace, nameOfBinding); err != nil {
+		response.WriteError(statenum, err)
+		return
+	}
+
+	err := rbr.k8sClient.RbacV1().RoleBindings(nameOfSpace).Delete(nameOfBinding, &metaV1.DeleteOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
+
+// checkSubjects only lets the ServiceAccounts of the namespace and the oidc users and groups
+// recorded on its accounts be bound, the namespace of ServiceAccount subjects is filled in
+func (rbr RoleBindingsResource) checkSubjects(nameOfSpace string, subjects []rbacv1.Subject) ([]rbacv1.Subject, int, error) {
+	serviceAccounts, err := rbr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, statusOfError(err), err
+	}
+	recorded := map[string]bool{}
+	for i := range serviceAccounts.Items {
+		users, groups := rbac.UsersAndGroupsOfServiceAccount(&serviceAccounts.Items[i])
+		for _, user := range users {
+			recorded[rbac.SubjectString(rbac.UserSubject(user))] = true
+		}
+		for _, group := range groups {
+			recorded[rbac.SubjectString(rbac.GroupSubject(group))] = true
+		}
+	}
+
+	var checked []rbacv1.Subject
+	var denied []string
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbacv1.ServiceAccountKind:
+			if subject.Namespace == "" {
+				subject.Namespace = nameOfSpace
+			}
+			if subject.Namespace != nameOfSpace {
+				denied = append(denied, rbac.SubjectString(subject))
+				continue
+			}
+		case rbacv1.UserKind:
+			subject = rbac.UserSubject(subject.Name)
+			if !recorded[rbac.SubjectString(subject)] {
+				denied = append(denied, rbac.SubjectString(subject))
+				continue
+			}
+		case rbacv1.GroupKind:
+			subject = rbac.GroupSubject(subject.Name)
+			if !recorded[rbac.SubjectString(subject)] {
+				denied = append(denied, rbac.SubjectString(subject))
+				continue
+			}
+		default:
+			denied = append(denied, rbac.SubjectString(subject))
+			continue
+		}
+		checked = append(checked, subject)
+	}
+	if len(denied) > 0 {
+		return nil, http.StatusForbidden, errors.New(fmt.Sprintf("subjects: %s are neither service accounts of %s nor oidc users or groups of its accounts",
+			strings.Join(denied, ", "), nameOfSpace))
+	}
+	return checked, http.StatusOK, nil
+}
+
+// bindings of accounts are maintained through profiles and grants, not through this resource
+func (rbr RoleBindingsResource) checkRoleBindingName(nameOfSpace, nameOfBinding string) (int, error) {
+	if !strings.HasPrefix(nameOfSpace, rbr.selfDefineResourcePrefix) {
+		return http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through rolebinding!", nameOfSpace))
+	}
+	if nameOfBinding == "" {
+		return http.StatusBadRequest, errors.New("name of the role binding is required")
+	}
+	if _, _, ok := rbac.ParseRoleBindingName(nameOfBinding); ok {
+		return http.StatusBadRequest, errors.New(
+			fmt.Sprintf("rolebinding: %s is managed by profiles and grants, cannot change through rolebinding!", nameOfBinding))
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,107 @@
+package restful
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	authorizationV1 "k8s.io/api/authorization/v1"
+	coreV1 "k8s.io/api/core/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sTesting "k8s.io/client-go/testing"
+)
+
+// fakeClientOfService lets the service itself do anything, so only the ceiling policy limits what is bound
+func fakeClientOfService(objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("create", "selfsubjectrulesreviews",
+		func(action k8sTesting.Action) (bool, runtime.Object, error) {
+			return true, &authorizationV1.SelfSubjectRulesReview{Status: authorizationV1.SubjectRulesReviewStatus{
+				ResourceRules: []authorizationV1.ResourceRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+			}}, nil
+		})
+	return client
+}
+
+func TestCreateRoleBinding(t *testing.T) {
+	podsReadonly := []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}}
+	client := fakeClientOfService(
+		&coreV1.ServiceAccount{ObjectMeta: metaV1.ObjectMeta{Name: "alice", Namespace: "clustar-a",
+			Annotations: map[string]string{rbac.UsersAnnotation: "alice@example.com", rbac.GroupsAnnotation: "team"}}},
+		&rbacV1.Role{ObjectMeta: metaV1.ObjectMeta{Name: "pod-reader", Namespace: "clustar-a"}, Rules: podsReadonly},
+		&rbacV1.Role{ObjectMeta: metaV1.ObjectMeta{Name: "pod-deleter", Namespace: "clustar-a"},
+			Rules: []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"delete"}}}},
+	)
+	container := restful.NewContainer()
+	container.Add(createRoleBindingResource(client, "clustar-", rbac.NewCeiling(podsReadonly, client)).WebService())
+
+	tests := []struct {
+		name    string
+		role    string
+		subject string
+		status  int
+	}{
+		{name: "service account of the namespace", role: "pod-reader",
+			subject: `{"kind": "ServiceAccount", "name": "bob"}`, status: http.StatusOK},
+		{name: "recorded oidc user", role: "pod-reader",
+			subject: `{"kind": "User", "name": "alice@example.com"}`, status: http.StatusOK},
+		{name: "recorded oidc group", role: "pod-reader",
+			subject: `{"kind": "Group", "name": "team"}`, status: http.StatusOK},
+		{name: "service account of another namespace", role: "pod-reader",
+			subject: `{"kind": "ServiceAccount", "name": "bob", "namespace": "clustar-b"}`, status: http.StatusForbidden},
+		{name: "every authenticated user", role: "pod-reader",
+			subject: `{"kind": "Group", "name": "system:authenticated"}`, status: http.StatusForbidden},
+		{name: "unknown user", role: "pod-reader",
+			subject: `{"kind": "User", "name": "mallory@example.com"}`, status: http.StatusForbidden},
+		{name: "role beyond the ceiling", role: "pod-deleter",
+			subject: `{"kind": "ServiceAccount", "name": "bob"}`, status: http.StatusForbidden},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"name": "binding-%d", "roleKind": "Role", "roleName": "%s", "subjects": [%s]}`,
+				i, test.role, test.subject)
+			request := httptest.NewRequest(http.MethodPost, "/rolebindings/clustar-a", strings.NewReader(body))
+			request.Header.Set("Content-Type", restful.MIME_JSON)
+			recorder := httptest.NewRecorder()
+			container.ServeHTTP(recorder, request)
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, test.status, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestCheckCustomRoles(t *testing.T) {
+	podsReadonly := []rbacV1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}}
+	client := fakeClientOfService(
+		&rbacV1.ClusterRole{ObjectMeta: metaV1.ObjectMeta{Name: "cluster-admin"},
+			Rules: []rbacV1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}}},
+		&rbacV1.Role{ObjectMeta: metaV1.ObjectMeta{Name: "pod-reader", Namespace: "clustar-a"}, Rules: podsReadonly},
+	)
+	kcr := KubeConfigResource{k8sClient: client, ceiling: rbac.NewCeiling(podsReadonly, client)}
+	tests := []struct {
+		name      string
+		role      rbac.RbacInterface
+		escalates bool
+	}{
+		{name: "profile role", role: rbac.NewNamespaceAdminRole("clustar-a", client)},
+		{name: "custom role within the ceiling", role: rbac.NewCustomRole("clustar-a", "Role", "pod-reader", client)},
+		{name: "custom cluster role beyond the ceiling", role: rbac.NewCustomRole("clustar-a", "ClusterRole", "cluster-admin", client),
+			escalates: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := kcr.checkCustomRoles([]rbac.RbacInterface{test.role})
+			if _, escalates := err.(*rbac.EscalationError); escalates != test.escalates || (err != nil && !escalates) {
+				t.Errorf("checkCustomRoles = %v, escalation expected: %v", err, test.escalates)
+			}
+		})
+	}
+}
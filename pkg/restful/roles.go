@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -15,12 +16,19 @@ import (
 type RolesResource struct {
 	k8sClient                kubernetes.Interface
 	selfDefineResourcePrefix string
+	ceiling                  *rbac.Ceiling
 }
 
-func createRoleResource(k8sClient kubernetes.Interface, prefix string) (resource *RolesResource) {
+type roleAction struct {
+	Name  string              `json:"name,omitempty" description:"name of the role, taken from the path on update"`
+	Rules []rbacv1.PolicyRule `json:"rules" description:"rules of the role"`
+}
+
+func createRoleResource(k8sClient kubernetes.Interface, prefix string, ceiling *rbac.Ceiling) (resource *RolesResource) {
 	resource = &RolesResource{
 		k8sClient:                k8sClient,
 		selfDefineResourcePrefix: prefix,
+		ceiling:                  ceiling,
 	}
 	return
 }
@@ -52,6 +60,29 @@ func (rr RolesResource) WebService() *restful.WebService {
 		Returns(200, "OK", nil).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(ws.POST("/{namespace}").To(rr.createRole).
+		// docs
+		Doc("create a role in specified namespace, its rules must not exceed the ceiling policy").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(roleAction{}).
+		Writes(rbacv1.Role{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(409, "Conflict", nil))
+
+	ws.Route(ws.PUT("/{namespace}/{role}").To(rr.updateRole).
+		// docs
+		Doc("replace the rules of specified role in specified namespace, they must not exceed the ceiling policy").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string")).
+		Param(ws.PathParameter("role", "identifier of the role").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(roleAction{}).
+		Writes(rbacv1.Role{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(403, "Forbidden", nil).
+		Returns(404, "Not Found", nil))
+
 	ws.Route(ws.DELETE("/{namespace}/{role}").To(rr.removeRole).
 		// docs
 		Doc("delete specified role in specified namespace").
@@ -106,11 +137,93 @@ func (rr RolesResource) removeRole(request *restful.Request, response *restful.R
 			errors.New(fmt.Sprintf("namespace: %s is not self define resouce, cannot remove through role!", nameOfSpace)))
 		return
 	}
+	if rbac.IsManagedRoleName(nameOfSpace, nameOfRole) {
+		response.WriteError(http.StatusBadRequest,
+			errors.New(fmt.Sprintf("role: %s is managed by profiles, cannot remove through role!", nameOfRole)))
+		return
+	}
 
-	err := rr.k8sClient.RbacV1().Roles(nameOfRole).Delete(nameOfRole, &metaV1.DeleteOptions{})
+	err := rr.k8sClient.RbacV1().Roles(nameOfSpace).Delete(nameOfRole, &metaV1.DeleteOptions{})
 	if err != nil {
 		response.WriteError(http.StatusInternalServerError, err)
 		return
 	}
 	response.Write([]byte("{\"status\":\"success\"}"))
 }
+
+// POST http://localhost:8080/roles/clustar-{ns}
+//
+func (rr RolesResource) createRole(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+
+	action := &roleAction{}
+	if err := request.ReadEntity(action); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if statenum, err := rr.checkRole(nameOfSpace, action.Name, action.Rules); err != nil {
+		response.WriteError(statenum, err)
+		return
+	}
+
+	roleTmp := &rbacv1.Role{}
+	roleTmp.APIVersion = "v1"
+	roleTmp.Kind = "Role"
+	roleTmp.Name = action.Name
+	roleTmp.Namespace = nameOfSpace
+	roleTmp.Rules = action.Rules
+	role, err := rr.k8sClient.RbacV1().Roles(nameOfSpace).Create(roleTmp)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(role)
+}
+
+// PUT http://localhost:8080/roles/clustar-{ns}/{role}
+//
+func (rr RolesResource) updateRole(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfRole := request.PathParameter("role")
+
+	action := &roleAction{}
+	if err := request.ReadEntity(action); err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if statenum, err := rr.checkRole(nameOfSpace, nameOfRole, action.Rules); err != nil {
+		response.WriteError(statenum, err)
+		return
+	}
+
+	role, err := rr.k8sClient.RbacV1().Roles(nameOfSpace).Get(nameOfRole, metaV1.GetOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	role.Rules = action.Rules
+	role, err = rr.k8sClient.RbacV1().Roles(nameOfSpace).Update(role)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(role)
+}
+
+func (rr RolesResource) checkRole(nameOfSpace, nameOfRole string, rules []rbacv1.PolicyRule) (int, error) {
+	if !strings.HasPrefix(nameOfSpace, rr.selfDefineResourcePrefix) {
+		return http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through role!", nameOfSpace))
+	}
+	if nameOfRole == "" {
+		return http.StatusBadRequest, errors.New("name of the role is required")
+	}
+	if rbac.IsManagedRoleName(nameOfSpace, nameOfRole) {
+		return http.StatusBadRequest, errors.New(
+			fmt.Sprintf("role: %s is managed by profiles, cannot change through role!", nameOfRole))
+	}
+	if err := rr.ceiling.CheckRole(nameOfSpace, rules); err != nil {
+		return statusOfCheckError(err), err
+	}
+	return http.StatusOK, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/emicklei/go-restful"
 	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
 	coreV1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -15,12 +16,14 @@ import (
 type ServiceAccountResource struct {
 	k8sClient                kubernetes.Interface
 	selfDefineResourcePrefix string
+	registry                 *rbac.Registry
 }
 
-func createServiceAccountResource(k8sclient kubernetes.Interface, prefix string) (resource *ServiceAccountResource) {
+func createServiceAccountResource(k8sclient kubernetes.Interface, prefix string, registry *rbac.Registry) (resource *ServiceAccountResource) {
 	resource = &ServiceAccountResource{
 		k8sClient:                k8sclient,
 		selfDefineResourcePrefix: prefix,
+		registry:                 registry,
 	}
 	return
 }
@@ -33,10 +36,19 @@ func (sar ServiceAccountResource) WebService() *restful.WebService {
 
 	tags := []string{"ServiceAccount"}
 
+	ws.Route(ws.GET("/").To(sar.findAllManagedServiceAccounts).
+		// docs
+		Doc("find the service accounts of all self define namespaces which carry the label of the service").
+		Param(ws.QueryParameter("detail", "true fills in the tokens, bindings and roles of every account, which are empty otherwise").DataType("boolean")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]serviceAccountEntity{}). // on the response
+		Returns(200, "OK", []serviceAccountEntity{}))
+
 	ws.Route(ws.GET("/{namespace}/").To(sar.findAllServiceAccount).
 		// docs
 		Doc("find all service account under specified namespace").
 		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.QueryParameter("detail", "true returns the tokens, bindings and roles of every account instead of its name").DataType("boolean")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes([]string{}). // on the response
 		Returns(200, "OK", nil).
@@ -47,6 +59,7 @@ func (sar ServiceAccountResource) WebService() *restful.WebService {
 		Doc("find specified service account under specified namespace").
 		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
 		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Param(ws.QueryParameter("detail", "true returns the tokens, bindings, roles and kubeconfig history of the account instead of the raw object").DataType("boolean")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(coreV1.ServiceAccount{}). // on the response
 		Returns(200, "OK", nil).
@@ -63,9 +76,20 @@ func (sar ServiceAccountResource) WebService() *restful.WebService {
 		Returns(200, "OK", nil).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(ws.POST("/{namespace}/{serviceAccount}/can-i").To(sar.canI).
+		// docs
+		Doc("check a batch of actions against the permissions of the service account").
+		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
+		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads([]canIAction{}).
+		Writes([]canIEntity{}). // on the response
+		Returns(200, "OK", nil).
+		Returns(400, "Bad Request", nil))
+
 	ws.Route(ws.DELETE("/{namespace}/{serviceAccount}").To(sar.removeServiceAccount).
 		// docs
-		Doc("delete specified service account in specified namespace").
+		Doc("delete specified service account in specified namespace together with its bindings and grants").
 		Param(ws.PathParameter("namespace", "identifier of the namespace").DataType("string").DefaultValue("default")).
 		Param(ws.PathParameter("serviceAccount", "identifier of the serviceAccount").DataType("string").DefaultValue("default")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
@@ -76,7 +100,7 @@ func (sar ServiceAccountResource) WebService() *restful.WebService {
 	return ws
 }
 
-// GET http://localhost:8080/serviceAccount/default
+// GET http://localhost:8080/serviceAccount/default?detail=true
 //
 func (sar ServiceAccountResource) findAllServiceAccount(request *restful.Request, response *restful.Response) {
 	nameOfSpace := request.PathParameter("namespace")
@@ -87,6 +111,16 @@ func (sar ServiceAccountResource) findAllServiceAccount(request *restful.Request
 		return
 	}
 
+	if request.QueryParameter("detail") == "true" {
+		entities, err := sar.collectServiceAccounts(nameOfSpace, serviceAccounts.Items)
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		response.WriteEntity(entities)
+		return
+	}
+
 	var list []string
 	for _, each := range serviceAccounts.Items {
 		list = append(list, each.Name)
@@ -94,7 +128,7 @@ func (sar ServiceAccountResource) findAllServiceAccount(request *restful.Request
 	response.WriteEntity(list)
 }
 
-// GET http://localhost:8080/serviceAccount/default/default
+// GET http://localhost:8080/serviceAccount/default/default?detail=true
 //
 func (sar ServiceAccountResource) getServiceAccount(request *restful.Request, response *restful.Response) {
 	nameOfSpace := request.PathParameter("namespace")
@@ -105,6 +139,15 @@ func (sar ServiceAccountResource) getServiceAccount(request *restful.Request, re
 		response.WriteError(http.StatusInternalServerError, err)
 		return
 	}
+	if request.QueryParameter("detail") == "true" {
+		entities, err := sar.collectServiceAccounts(nameOfSpace, []coreV1.ServiceAccount{*serviceAccount})
+		if err != nil {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		response.WriteEntity(entities[0])
+		return
+	}
 	response.WriteEntity(serviceAccount)
 }
 
@@ -145,7 +188,13 @@ func (sar *ServiceAccountResource) removeServiceAccount(request *restful.Request
 		return
 	}
 
-	err := sar.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Delete(nameOfAccount, &metaV1.DeleteOptions{})
+	// the same as deleting through /kubeconfig, no binding of the account is left behind
+	err := sar.registry.Deprovision(nameOfSpace, nameOfAccount)
+	if err != nil {
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+	err = sar.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Delete(nameOfAccount, &metaV1.DeleteOptions{})
 	if err != nil {
 		response.WriteError(http.StatusInternalServerError, err)
 		return
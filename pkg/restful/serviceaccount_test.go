@@ -0,0 +1,73 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRemoveServiceAccountDeprovisions(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&coreV1.ServiceAccount{ObjectMeta: metaV1.ObjectMeta{Name: "alice", Namespace: "clustar-a"}})
+	registry := rbac.NewRegistry(rbac.HelmModeDisabled, "", "", client)
+	subjects := rbac.AccountSubjects("clustar-a", "alice", true, nil, nil)
+	if err := registry.Provision("clustar-a", "alice", subjects,
+		[]rbac.RbacInterface{rbac.NewNamespaceViewerRole("clustar-a", client)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Grant("clustar-a", "alice", "clustar-b", subjects,
+		[]rbac.RbacInterface{rbac.NewNamespaceEditorRole("clustar-b", client)}); err != nil {
+		t.Fatal(err)
+	}
+
+	container := restful.NewContainer()
+	container.Add(createServiceAccountResource(client, "clustar-", registry).WebService())
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/serviceAccount/clustar-a/alice", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	bindings, err := rbac.ListAccountRoleBindings(client, "clustar-a", "alice", metaV1.NamespaceAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, each := range bindings {
+		t.Errorf("binding %s/%s is left behind", each.Namespace, each.Name)
+	}
+	if _, err := client.CoreV1().ServiceAccounts("clustar-a").Get("alice", metaV1.GetOptions{}); err == nil {
+		t.Error("the service account is left behind")
+	}
+}
+
+func TestFindAllManagedServiceAccounts(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-a",
+			Labels: map[string]string{managedByLabel: managedByValue}}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-b"}},
+		&coreV1.ServiceAccount{ObjectMeta: metaV1.ObjectMeta{Name: "alice", Namespace: "clustar-a"}},
+		&coreV1.ServiceAccount{ObjectMeta: metaV1.ObjectMeta{Name: "bob", Namespace: "clustar-b"}},
+	)
+	container := restful.NewContainer()
+	container.Add(createServiceAccountResource(client, "clustar-",
+		rbac.NewRegistry(rbac.HelmModeDisabled, "", "", client)).WebService())
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/serviceAccount/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var accounts []serviceAccountEntity
+	if err := json.Unmarshal(recorder.Body.Bytes(), &accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Namespace != "clustar-a" || accounts[0].Name != "alice" {
+		t.Errorf("accounts = %+v, want clustar-a/alice only", accounts)
+	}
+}
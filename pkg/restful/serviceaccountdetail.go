@@ -0,0 +1,256 @@
+package restful
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	coreV1 "k8s.io/api/core/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type tokenSecretEntity struct {
+	Name         string `json:"name" description:"name of the secret, the token itself is never returned"`
+	Type         string `json:"type" description:"type of the secret"`
+	CreationTime string `json:"creationTime" description:"when the secret was created"`
+}
+
+type accountBindingEntity struct {
+	Kind      string   `json:"kind" description:"RoleBinding or ClusterRoleBinding"`
+	Namespace string   `json:"namespace,omitempty" description:"namespace of a RoleBinding"`
+	Name      string   `json:"name" description:"name of the binding"`
+	RoleRef   string   `json:"roleRef" description:"role bound, as Kind/Name"`
+	Subjects  []string `json:"subjects" description:"subjects of the binding standing for the account"`
+}
+
+type grantedRoleEntity struct {
+	Kind      string   `json:"kind" description:"Role or ClusterRole"`
+	Name      string   `json:"name" description:"name of the role"`
+	Namespace string   `json:"namespace,omitempty" description:"namespace the role applies to, empty when it applies cluster wide"`
+	Bindings  []string `json:"bindings" description:"bindings which grant the role"`
+}
+
+type serviceAccountEntity struct {
+	Namespace           string                 `json:"namespace" description:"namespace of the account"`
+	Name                string                 `json:"name" description:"name of the account"`
+	CreationTime        string                 `json:"creationTime" description:"when the account was created"`
+	Annotations         map[string]string      `json:"annotations,omitempty" description:"annotations of the service, recording the profiles and subjects of the account"`
+	BindsServiceAccount bool                   `json:"bindsServiceAccount" description:"whether the ServiceAccount itself is bound, or only its users and groups"`
+	Users               []string               `json:"users,omitempty" description:"oidc users bound together with the account"`
+	Groups              []string               `json:"groups,omitempty" description:"oidc groups bound together with the account"`
+	Tokens              []tokenSecretEntity    `json:"tokens" description:"token secrets of the ServiceAccount"`
+	Bindings            []accountBindingEntity `json:"bindings" description:"bindings of the whole cluster referring to the account"`
+	Roles               []grantedRoleEntity    `json:"roles" description:"roles the account is effectively granted"`
+	LastIssued          string                 `json:"lastIssued,omitempty" description:"when a kubeconfig was last issued for the account"`
+	LastRotated         string                 `json:"lastRotated,omitempty" description:"when the token of the account was last rotated"`
+}
+
+// accountSubjects returns every subject a binding may name to grant something to the account, by the string
+// of the subject: the ServiceAccount, the user and groups its token authenticates as, and its recorded users and groups
+func accountSubjects(serviceAccount *coreV1.ServiceAccount) map[string]bool {
+	subjects := map[string]bool{}
+	for _, subject := range []rbacV1.Subject{
+		rbac.ServiceAccountSubject(serviceAccount.Namespace, serviceAccount.Name),
+		rbac.UserSubject(fmt.Sprintf(serviceAccountUsernamePattern, serviceAccount.Namespace, serviceAccount.Name)),
+		rbac.GroupSubject(serviceAccountsGroup),
+		rbac.GroupSubject(serviceAccountsGroup + ":" + serviceAccount.Namespace),
+		rbac.GroupSubject(authenticatedGroup),
+	} {
+		subjects[rbac.SubjectString(subject)] = true
+	}
+	users, groups := rbac.UsersAndGroupsOfServiceAccount(serviceAccount)
+	for _, subject := range rbac.AccountSubjects(serviceAccount.Namespace, serviceAccount.Name, false, users, groups) {
+		subjects[rbac.SubjectString(subject)] = true
+	}
+	return subjects
+}
+
+// matchingSubjects returns the subjects of a binding which stand for the account,
+// the namespace of a ServiceAccount subject defaults to the one of the RoleBinding
+func matchingSubjects(subjects []rbacV1.Subject, bindingNamespace string, ofAccount map[string]bool) []string {
+	var matched []string
+	for _, subject := range subjects {
+		if subject.Kind == rbacV1.ServiceAccountKind && subject.Namespace == "" {
+			subject.Namespace = bindingNamespace
+		}
+		if subject.Kind != rbacV1.ServiceAccountKind {
+			subject.Namespace = ""
+		}
+		if ofAccount[rbac.SubjectString(subject)] {
+			matched = append(matched, rbac.SubjectString(subject))
+		}
+	}
+	return matched
+}
+
+// newServiceAccountEntity returns what the ServiceAccount itself tells about the account,
+// its tokens, bindings and roles are left empty
+func newServiceAccountEntity(serviceAccount *coreV1.ServiceAccount) serviceAccountEntity {
+	entity := serviceAccountEntity{
+		Namespace:           serviceAccount.Namespace,
+		Name:                serviceAccount.Name,
+		CreationTime:        serviceAccount.CreationTimestamp.Format(time.RFC3339),
+		Annotations:         map[string]string{},
+		BindsServiceAccount: rbac.BindsServiceAccount(serviceAccount),
+		Tokens:              []tokenSecretEntity{},
+		Bindings:            []accountBindingEntity{},
+		Roles:               []grantedRoleEntity{},
+		LastIssued:          serviceAccount.Annotations[kubeConfigIssuedAnnotation],
+		LastRotated:         serviceAccount.Annotations[tokenRotatedAnnotation],
+	}
+	entity.Users, entity.Groups = rbac.UsersAndGroupsOfServiceAccount(serviceAccount)
+	for key, value := range serviceAccount.Annotations {
+		if strings.HasPrefix(key, serviceAnnotationPrefix) {
+			entity.Annotations[key] = value
+		}
+	}
+	return entity
+}
+
+// collectServiceAccounts details accounts, namespace is metaV1.NamespaceAll when they span many tenants.
+// Bindings and secrets are listed once for all of them.
+func (sar ServiceAccountResource) collectServiceAccounts(namespace string, accounts []coreV1.ServiceAccount) (
+	[]serviceAccountEntity, error) {
+	roleBindings, err := sar.k8sClient.RbacV1().RoleBindings(metaV1.NamespaceAll).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	clusterRoleBindings, err := sar.k8sClient.RbacV1().ClusterRoleBindings().List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := sar.k8sClient.CoreV1().Secrets(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []serviceAccountEntity{}
+	for i := range accounts {
+		serviceAccount := &accounts[i]
+		entity := newServiceAccountEntity(serviceAccount)
+
+		referenced := map[string]bool{}
+		for _, each := range serviceAccount.Secrets {
+			referenced[each.Name] = true
+		}
+		for _, secret := range secrets.Items {
+			if secret.Namespace != serviceAccount.Namespace {
+				continue
+			}
+			ownToken := secret.Type == coreV1.SecretTypeServiceAccountToken &&
+				secret.Annotations[coreV1.ServiceAccountNameKey] == serviceAccount.Name
+			if ownToken || referenced[secret.Name] {
+				entity.Tokens = append(entity.Tokens, tokenSecretEntity{
+					Name:         secret.Name,
+					Type:         string(secret.Type),
+					CreationTime: secret.CreationTimestamp.Format(time.RFC3339),
+				})
+			}
+		}
+
+		ofAccount := accountSubjects(serviceAccount)
+		roles := map[string]*grantedRoleEntity{}
+		grant := func(kind, name, scope, binding string) {
+			key := fmt.Sprintf("%s/%s/%s", scope, kind, name)
+			if roles[key] == nil {
+				roles[key] = &grantedRoleEntity{Kind: kind, Name: name, Namespace: scope}
+			}
+			roles[key].Bindings = append(roles[key].Bindings, binding)
+		}
+		for _, each := range roleBindings.Items {
+			matched := matchingSubjects(each.Subjects, each.Namespace, ofAccount)
+			if len(matched) == 0 {
+				continue
+			}
+			entity.Bindings = append(entity.Bindings, accountBindingEntity{
+				Kind:      "RoleBinding",
+				Namespace: each.Namespace,
+				Name:      each.Name,
+				RoleRef:   fmt.Sprintf("%s/%s", each.RoleRef.Kind, each.RoleRef.Name),
+				Subjects:  matched,
+			})
+			grant(each.RoleRef.Kind, each.RoleRef.Name, each.Namespace, fmt.Sprintf("RoleBinding/%s/%s", each.Namespace, each.Name))
+		}
+		for _, each := range clusterRoleBindings.Items {
+			matched := matchingSubjects(each.Subjects, "", ofAccount)
+			if len(matched) == 0 {
+				continue
+			}
+			entity.Bindings = append(entity.Bindings, accountBindingEntity{
+				Kind:     "ClusterRoleBinding",
+				Name:     each.Name,
+				RoleRef:  fmt.Sprintf("%s/%s", each.RoleRef.Kind, each.RoleRef.Name),
+				Subjects: matched,
+			})
+			grant(each.RoleRef.Kind, each.RoleRef.Name, "", "ClusterRoleBinding/"+each.Name)
+		}
+		for _, role := range roles {
+			sort.Strings(role.Bindings)
+			entity.Roles = append(entity.Roles, *role)
+		}
+		sort.Slice(entity.Roles, func(i, j int) bool {
+			a, b := entity.Roles[i], entity.Roles[j]
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			if a.Kind != b.Kind {
+				return a.Kind < b.Kind
+			}
+			return a.Name < b.Name
+		})
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+// GET http://localhost:8080/serviceAccount/?detail=true
+//
+func (sar ServiceAccountResource) findAllManagedServiceAccounts(request *restful.Request, response *restful.Response) {
+	namespaces, err := sar.k8sClient.CoreV1().Namespaces().List(metaV1.ListOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	tenants := map[string]bool{}
+	for i := range namespaces.Items {
+		if isManagedNamespace(sar.selfDefineResourcePrefix, &namespaces.Items[i]) {
+			tenants[namespaces.Items[i].Name] = true
+		}
+	}
+	serviceAccounts, err := sar.k8sClient.CoreV1().ServiceAccounts(metaV1.NamespaceAll).List(metaV1.ListOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	var accounts []coreV1.ServiceAccount
+	for _, each := range serviceAccounts.Items {
+		if tenants[each.Namespace] && each.Name != "default" {
+			accounts = append(accounts, each)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Namespace != accounts[j].Namespace {
+			return accounts[i].Namespace < accounts[j].Namespace
+		}
+		return accounts[i].Name < accounts[j].Name
+	})
+
+	if request.QueryParameter("detail") != "true" {
+		entities := []serviceAccountEntity{}
+		for i := range accounts {
+			entities = append(entities, newServiceAccountEntity(&accounts[i]))
+		}
+		response.WriteEntity(entities)
+		return
+	}
+	entities, err := sar.collectServiceAccounts(metaV1.NamespaceAll, accounts)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(entities)
+}
@@ -0,0 +1,85 @@
+package restful
+
+import (
+	"github.com/emicklei/go-restful"
+	"github.com/emicklei/go-restful-openapi"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+)
+
+type StorageClassResource struct {
+	classes *storage.Classes
+}
+
+func createStorageClassResource(classes *storage.Classes) (resource *StorageClassResource) {
+	resource = &StorageClassResource{
+		classes: classes,
+	}
+	return
+}
+
+type storageClassEntity struct {
+	Name                 string            `json:"name" description:"name of the storage class"`
+	Provisioner          string            `json:"provisioner" description:"provisioner creating the volumes of the class"`
+	Parameters           map[string]string `json:"parameters,omitempty" description:"parameters passed to the provisioner"`
+	ReclaimPolicy        string            `json:"reclaimPolicy,omitempty" description:"what happens to the volumes once released"`
+	VolumeBindingMode    string            `json:"volumeBindingMode,omitempty" description:"when the claims of the class are bound"`
+	AllowVolumeExpansion bool              `json:"allowVolumeExpansion" description:"whether the volumes of the class can be expanded"`
+	Default              bool              `json:"default" description:"whether claims without a class use this one"`
+	Allowed              bool              `json:"allowed" description:"whether tenants may provision volumes from the class"`
+}
+
+func (scr StorageClassResource) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/storageclasses").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"storageclasses"}
+
+	ws.Route(ws.GET("/").To(scr.findAllStorageClasses).
+		// docs
+		Doc("get all storage classes, and whether tenants may provision volumes from them").
+		Param(ws.QueryParameter("allowed", "only the classes tenants may use").DataType("boolean")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]storageClassEntity{}).
+		Returns(200, "OK", []storageClassEntity{}))
+
+	return ws
+}
+
+// GET http://localhost:8080/storageclasses?allowed=true
+//
+func (scr StorageClassResource) findAllStorageClasses(request *restful.Request, response *restful.Response) {
+	onlyAllowed := request.QueryParameter("allowed") == "true"
+
+	classes, err := scr.classes.List()
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	list := []storageClassEntity{}
+	for i := range classes {
+		class := &classes[i]
+		entity := storageClassEntity{
+			Name:        class.Name,
+			Provisioner: class.Provisioner,
+			Parameters:  class.Parameters,
+			Default:     storage.IsDefaultClass(class),
+			Allowed:     scr.classes.IsAllowed(class.Name),
+		}
+		if onlyAllowed && !entity.Allowed {
+			continue
+		}
+		if class.ReclaimPolicy != nil {
+			entity.ReclaimPolicy = string(*class.ReclaimPolicy)
+		}
+		if class.VolumeBindingMode != nil {
+			entity.VolumeBindingMode = string(*class.VolumeBindingMode)
+		}
+		if class.AllowVolumeExpansion != nil {
+			entity.AllowVolumeExpansion = *class.AllowVolumeExpansion
+		}
+		list = append(list, entity)
+	}
+	response.WriteEntity(list)
+}
@@ -0,0 +1,159 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/rbac"
+	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// kubeConfigIssuedAnnotation and tokenRotatedAnnotation record on the ServiceAccount
+	// when a kubeconfig was last issued for the account and when its token was last rotated
+	kubeConfigIssuedAnnotation = "clustar.ai/kubeconfig-issued-at"
+	tokenRotatedAnnotation     = "clustar.ai/token-rotated-at"
+
+	defaultRotateTimeout = 30 * time.Second
+	tokenPollInterval    = time.Second
+)
+
+type tokenRotationEntity struct {
+	Namespace string            `json:"namespace" description:"namespace of the account"`
+	Name      string            `json:"name" description:"name of the account"`
+	Revoked   []string          `json:"revoked" description:"token secrets which were deleted"`
+	Token     tokenSecretEntity `json:"token" description:"token secret issued instead"`
+	RotatedAt string            `json:"rotatedAt" description:"when the token was rotated"`
+}
+
+// recordAccountTimestamp sets annotation of the ServiceAccount to now
+func (kcr KubeConfigResource) recordAccountTimestamp(nameOfSpace, nameOfAccount, annotation string, now time.Time) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		serviceAccount, err := kcr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Get(nameOfAccount, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if serviceAccount.Annotations == nil {
+			serviceAccount.Annotations = map[string]string{}
+		}
+		serviceAccount.Annotations[annotation] = now.UTC().Format(time.RFC3339)
+		_, err = kcr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Update(serviceAccount)
+		return err
+	})
+}
+
+// tokenSecretsOf returns the token secrets the token controller issued for the ServiceAccount
+func (kcr KubeConfigResource) tokenSecretsOf(serviceAccount *coreV1.ServiceAccount) ([]coreV1.Secret, error) {
+	secrets, err := kcr.k8sClient.CoreV1().Secrets(serviceAccount.Namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var tokens []coreV1.Secret
+	for _, secret := range secrets.Items {
+		if secret.Type == coreV1.SecretTypeServiceAccountToken &&
+			secret.Annotations[coreV1.ServiceAccountNameKey] == serviceAccount.Name &&
+			secret.Annotations[coreV1.ServiceAccountUIDKey] == string(serviceAccount.UID) {
+			tokens = append(tokens, secret)
+		}
+	}
+	return tokens, nil
+}
+
+// issuedTokenOf returns the newest token secret of the ServiceAccount the token controller has filled in,
+// nil while there is none. Secrets in skipped, the revoked ones, are not considered.
+func (kcr KubeConfigResource) issuedTokenOf(serviceAccount *coreV1.ServiceAccount, skipped map[string]bool) (*coreV1.Secret, error) {
+	tokens, err := kcr.tokenSecretsOf(serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+	var issued *coreV1.Secret
+	for i := range tokens {
+		if skipped[tokens[i].Name] || len(tokens[i].Data[coreV1.ServiceAccountTokenKey]) == 0 {
+			continue
+		}
+		if issued == nil || issued.CreationTimestamp.Before(&tokens[i].CreationTimestamp) {
+			issued = &tokens[i]
+		}
+	}
+	return issued, nil
+}
+
+// POST http://localhost:8080/kubeconfig/clustar-{ns}/default/rotate?timeout=30
+//
+func (kcr KubeConfigResource) rotateToken(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfAccount := request.PathParameter("serviceAccount")
+
+	if !strings.HasPrefix(nameOfSpace, kcr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot use through service!", nameOfSpace)))
+		return
+	}
+	timeout, err := timeoutParameter(request, defaultRotateTimeout)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	serviceAccount, err := kcr.k8sClient.CoreV1().ServiceAccounts(nameOfSpace).Get(nameOfAccount, metaV1.GetOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if !rbac.BindsServiceAccount(serviceAccount) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("serviceAccount: %s/%s is not bound, it has no token to rotate!", nameOfSpace, nameOfAccount)))
+		return
+	}
+
+	tokens, err := kcr.tokenSecretsOf(serviceAccount)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	entity := tokenRotationEntity{Namespace: nameOfSpace, Name: nameOfAccount, Revoked: []string{}}
+	revoked := map[string]bool{}
+	for _, token := range tokens {
+		err := kcr.k8sClient.CoreV1().Secrets(nameOfSpace).Delete(token.Name, &metaV1.DeleteOptions{})
+		if err != nil && !k8sError.IsNotFound(err) {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		revoked[token.Name] = true
+		entity.Revoked = append(entity.Revoked, token.Name)
+	}
+	now := time.Now()
+	if err := kcr.recordAccountTimestamp(nameOfSpace, nameOfAccount, tokenRotatedAnnotation, now); err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	entity.RotatedAt = now.UTC().Format(time.RFC3339)
+
+	// the token controller issues a new secret once the old ones are gone from the ServiceAccount
+	var issued *coreV1.Secret
+	err = wait.PollImmediate(tokenPollInterval, timeout, func() (bool, error) {
+		issued, err = kcr.issuedTokenOf(serviceAccount, revoked)
+		return issued != nil, err
+	})
+	if err == wait.ErrWaitTimeout {
+		response.WriteHeaderAndEntity(http.StatusGatewayTimeout, entity)
+		return
+	}
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	entity.Token = tokenSecretEntity{
+		Name:         issued.Name,
+		Type:         string(issued.Type),
+		CreationTime: issued.CreationTimestamp.Format(time.RFC3339),
+	}
+	response.WriteEntity(entity)
+}
@@ -0,0 +1,74 @@
+package restful
+
+import (
+	"testing"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIssuedTokenOf(t *testing.T) {
+	serviceAccount := &coreV1.ServiceAccount{
+		ObjectMeta: metaV1.ObjectMeta{Name: "alice", Namespace: "clustar-a", UID: "alice-uid"},
+		// the first secret referenced was revoked by a rotation
+		Secrets: []coreV1.ObjectReference{{Name: "alice-token-old"}, {Name: "alice-token-new"}},
+	}
+	token := func(name, token string, created time.Time) *coreV1.Secret {
+		return &coreV1.Secret{
+			ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "clustar-a", CreationTimestamp: metaV1.NewTime(created),
+				Annotations: map[string]string{
+					coreV1.ServiceAccountNameKey: "alice",
+					coreV1.ServiceAccountUIDKey:  "alice-uid",
+				}},
+			Type: coreV1.SecretTypeServiceAccountToken,
+			Data: map[string][]byte{coreV1.ServiceAccountTokenKey: []byte(token)},
+		}
+	}
+	now := time.Now()
+	tests := []struct {
+		name    string
+		secrets []runtime.Object
+		skipped map[string]bool
+		want    string
+	}{
+		{name: "no secret"},
+		{name: "token not filled in yet", secrets: []runtime.Object{token("alice-token-new", "", now)}},
+		{
+			name:    "newest token",
+			secrets: []runtime.Object{token("alice-token-old", "old", now.Add(-time.Hour)), token("alice-token-new", "new", now)},
+			want:    "alice-token-new",
+		},
+		{
+			name:    "revoked token skipped",
+			secrets: []runtime.Object{token("alice-token-old", "old", now.Add(-time.Hour))},
+			skipped: map[string]bool{"alice-token-old": true},
+		},
+		{
+			name: "token of a former account of the same name",
+			secrets: []runtime.Object{func() *coreV1.Secret {
+				secret := token("alice-token-new", "new", now)
+				secret.Annotations[coreV1.ServiceAccountUIDKey] = "former-uid"
+				return secret
+			}()},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kcr := KubeConfigResource{k8sClient: fake.NewSimpleClientset(test.secrets...)}
+			issued, err := kcr.issuedTokenOf(serviceAccount, test.skipped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := ""
+			if issued != nil {
+				name = issued.Name
+			}
+			if name != test.want {
+				t.Errorf("issuedTokenOf = %q, want %q", name, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/capability"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// gpuResource is the extended resource the nvidia device plugin advertises
+const gpuResource coreV1.ResourceName = "nvidia.com/gpu"
+
+type resourceUsageEntity struct {
+	CPU    string `json:"cpu" description:"cores"`
+	Memory string `json:"memory" description:"bytes"`
+	GPU    string `json:"gpu" description:"nvidia gpus"`
+}
+
+type usageEntity struct {
+	Namespace              string                 `json:"namespace" description:"name of the namespace"`
+	Pods                   map[string]int         `json:"pods" description:"number of pods by phase"`
+	Requests               resourceUsageEntity    `json:"requests" description:"resources requested by the pods which have not terminated"`
+	Limits                 resourceUsageEntity    `json:"limits" description:"resource limits of the pods which have not terminated"`
+	PersistentVolumeClaims int                    `json:"persistentVolumeClaims" description:"number of pvc"`
+	StorageCapacity        string                 `json:"storageCapacity" description:"capacity of the bound pvc, and the request of the others"`
+	Services               int                    `json:"services" description:"number of services"`
+	LoadBalancers          int                    `json:"loadBalancers" description:"number of services of type LoadBalancer"`
+	TFJobs                 map[string]int         `json:"tfjobs,omitempty" description:"number of tfjobs by status, missing when kubeflow is not installed"`
+	Quota                  map[string]quotaEntity `json:"quota" description:"hard and used quantities of every resource quota, by resource"`
+
+	requests coreV1.ResourceList
+	limits   coreV1.ResourceList
+	storage  resource.Quantity
+}
+
+// usageSortKeys maps the sortBy parameter of the usage table onto the value compared
+var usageSortKeys = map[string]func(usage *usageEntity) resource.Quantity{
+	"pods": func(usage *usageEntity) resource.Quantity {
+		pods := 0
+		for _, count := range usage.Pods {
+			pods += count
+		}
+		return *resource.NewQuantity(int64(pods), resource.DecimalSI)
+	},
+	"cpuRequests":    func(usage *usageEntity) resource.Quantity { return usage.requests[coreV1.ResourceCPU] },
+	"memoryRequests": func(usage *usageEntity) resource.Quantity { return usage.requests[coreV1.ResourceMemory] },
+	"gpuRequests":    func(usage *usageEntity) resource.Quantity { return usage.requests[gpuResource] },
+	"cpuLimits":      func(usage *usageEntity) resource.Quantity { return usage.limits[coreV1.ResourceCPU] },
+	"memoryLimits":   func(usage *usageEntity) resource.Quantity { return usage.limits[coreV1.ResourceMemory] },
+	"gpuLimits":      func(usage *usageEntity) resource.Quantity { return usage.limits[gpuResource] },
+	"storage":        func(usage *usageEntity) resource.Quantity { return usage.storage },
+	"persistentVolumeClaims": func(usage *usageEntity) resource.Quantity {
+		return *resource.NewQuantity(int64(usage.PersistentVolumeClaims), resource.DecimalSI)
+	},
+	"services": func(usage *usageEntity) resource.Quantity {
+		return *resource.NewQuantity(int64(usage.Services), resource.DecimalSI)
+	},
+	"loadBalancers": func(usage *usageEntity) resource.Quantity {
+		return *resource.NewQuantity(int64(usage.LoadBalancers), resource.DecimalSI)
+	},
+}
+
+func newUsageEntity(namespace string) *usageEntity {
+	return &usageEntity{
+		Namespace: namespace,
+		Pods:      map[string]int{},
+		Quota:     map[string]quotaEntity{},
+		requests:  coreV1.ResourceList{},
+		limits:    coreV1.ResourceList{},
+	}
+}
+
+// GET http://localhost:8080/namespaces/clustar-{ns}/usage
+//
+func (nsr NameSpacesResource) findUsage(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	if _, err := nsr.k8sClient.CoreV1().Namespaces().Get(nameOfSpace, metaV1.GetOptions{}); err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	usages, err := nsr.collectUsage(nameOfSpace, []string{nameOfSpace})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.WriteEntity(usages[0])
+}
+
+// GET http://localhost:8080/namespaces/usage?sortBy=cpuRequests&order=desc
+//
+func (nsr NameSpacesResource) findAllUsage(request *restful.Request, response *restful.Response) {
+	sortBy := request.QueryParameter("sortBy")
+	descending := request.QueryParameter("order") == "desc"
+	key, ok := usageSortKeys[sortBy]
+	if sortBy != "" && sortBy != "namespace" && !ok {
+		var keys []string
+		for each := range usageSortKeys {
+			keys = append(keys, each)
+		}
+		sort.Strings(keys)
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("invalid sortBy: %s, must be namespace or one of %s", sortBy, strings.Join(keys, ", "))))
+		return
+	}
+
+	namespaces, err := nsr.k8sClient.CoreV1().Namespaces().List(metaV1.ListOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	var tenants []string
+	for i := range namespaces.Items {
+		if isManagedNamespace(nsr.selfDefineResourcePrefix, &namespaces.Items[i]) {
+			tenants = append(tenants, namespaces.Items[i].Name)
+		}
+	}
+	usages, err := nsr.collectUsage(metaV1.NamespaceAll, tenants)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		if key != nil {
+			left, right := key(usages[i]), key(usages[j])
+			if c := left.Cmp(right); c != 0 {
+				return (c < 0) != descending
+			}
+		}
+		return (usages[i].Namespace < usages[j].Namespace) != (descending && key == nil)
+	})
+	response.WriteEntity(usages)
+}
+
+// collectUsage lists the objects of namespace, which is metaV1.NamespaceAll for many tenants at once,
+// and sums them up for every tenant
+func (nsr NameSpacesResource) collectUsage(namespace string, tenants []string) ([]*usageEntity, error) {
+	byNamespace := map[string]*usageEntity{}
+	usages := []*usageEntity{}
+	for _, tenant := range tenants {
+		usage := newUsageEntity(tenant)
+		byNamespace[tenant] = usage
+		usages = append(usages, usage)
+	}
+	options := metaV1.ListOptions{}
+
+	pods, err := nsr.k8sClient.CoreV1().Pods(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		usage, ok := byNamespace[pod.Namespace]
+		if !ok {
+			continue
+		}
+		usage.Pods[string(pod.Status.Phase)]++
+		if pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed {
+			continue
+		}
+		requests, limits := podResources(pod)
+		addResources(usage.requests, requests)
+		addResources(usage.limits, limits)
+	}
+
+	pvcs, err := nsr.k8sClient.CoreV1().PersistentVolumeClaims(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcs.Items {
+		usage, ok := byNamespace[pvc.Namespace]
+		if !ok {
+			continue
+		}
+		usage.PersistentVolumeClaims++
+		capacity, bound := pvc.Status.Capacity[coreV1.ResourceStorage]
+		if !bound {
+			capacity = pvc.Spec.Resources.Requests[coreV1.ResourceStorage]
+		}
+		usage.storage.Add(capacity)
+	}
+
+	services, err := nsr.k8sClient.CoreV1().Services(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services.Items {
+		usage, ok := byNamespace[service.Namespace]
+		if !ok {
+			continue
+		}
+		usage.Services++
+		if service.Spec.Type == coreV1.ServiceTypeLoadBalancer {
+			usage.LoadBalancers++
+		}
+	}
+
+	quotas, err := nsr.k8sClient.CoreV1().ResourceQuotas(namespace).List(options)
+	if err != nil {
+		return nil, err
+	}
+	quotasByNamespace := map[string][]coreV1.ResourceQuota{}
+	for _, quota := range quotas.Items {
+		quotasByNamespace[quota.Namespace] = append(quotasByNamespace[quota.Namespace], quota)
+	}
+	for tenant, usage := range byNamespace {
+		usage.Quota = summarizeQuotas(quotasByNamespace[tenant])
+	}
+
+	if err := nsr.collectTFJobs(namespace, byNamespace); err != nil {
+		return nil, err
+	}
+
+	for _, usage := range usages {
+		usage.Requests = newResourceUsageEntity(usage.requests)
+		usage.Limits = newResourceUsageEntity(usage.limits)
+		usage.StorageCapacity = usage.storage.String()
+	}
+	return usages, nil
+}
+
+// collectTFJobs counts the tfjobs by the last condition they reached, nothing is counted without kubeflow
+func (nsr NameSpacesResource) collectTFJobs(namespace string, byNamespace map[string]*usageEntity) error {
+	groupVersion := nsr.detector.GroupVersion(capability.TFJobs)
+	if groupVersion == "" {
+		return nil
+	}
+	gv, err := schema.ParseGroupVersion(groupVersion)
+	if err != nil {
+		return err
+	}
+	tfjobs, err := nsr.dynamicClient.Resource(gv.WithResource("tfjobs")).Namespace(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, usage := range byNamespace {
+		usage.TFJobs = map[string]int{}
+	}
+	for _, tfjob := range tfjobs.Items {
+		usage, ok := byNamespace[tfjob.GetNamespace()]
+		if !ok {
+			continue
+		}
+		usage.TFJobs[tfjobStatus(tfjob)]++
+	}
+	return nil
+}
+
+// tfjobStatus returns the type of the last condition which is true, such as Created, Running, Succeeded or Failed
+func tfjobStatus(tfjob unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(tfjob.Object, "status", "conditions")
+	status := "Unknown"
+	for _, each := range conditions {
+		condition, ok := each.(map[string]interface{})
+		if !ok || condition["status"] != string(coreV1.ConditionTrue) {
+			continue
+		}
+		if conditionType, ok := condition["type"].(string); ok {
+			status = conditionType
+		}
+	}
+	return status
+}
+
+// podResources returns what the scheduler accounts for a pod, the larger of its containers together
+// and of its biggest init container
+func podResources(pod *coreV1.Pod) (requests, limits coreV1.ResourceList) {
+	requests, limits = coreV1.ResourceList{}, coreV1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResources(requests, container.Resources.Requests)
+		addResources(limits, container.Resources.Limits)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		maxResources(requests, container.Resources.Requests)
+		maxResources(limits, container.Resources.Limits)
+	}
+	return
+}
+
+func addResources(total, list coreV1.ResourceList) {
+	for name, quantity := range list {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
+
+func maxResources(total, list coreV1.ResourceList) {
+	for name, quantity := range list {
+		if current, ok := total[name]; !ok || quantity.Cmp(current) > 0 {
+			total[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+func newResourceUsageEntity(list coreV1.ResourceList) resourceUsageEntity {
+	cpu, memory, gpu := list[coreV1.ResourceCPU], list[coreV1.ResourceMemory], list[gpuResource]
+	return resourceUsageEntity{CPU: cpu.String(), Memory: memory.String(), GPU: gpu.String()}
+}
@@ -0,0 +1,348 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultReleaseTimeout = 60 * time.Second
+	defaultBindTimeout    = 60 * time.Second
+	volumePollInterval    = time.Second
+)
+
+// volumeEntity is a PV together with the claim bound to it, either of them may be missing
+type volumeEntity struct {
+	PvName        string   `json:"pvName,omitempty" description:"name of the pv"`
+	PvcName       string   `json:"pvcName,omitempty" description:"name of the pvc"`
+	NameSpace     string   `json:"namespace,omitempty" description:"namespace of the pvc"`
+	Phase         string   `json:"phase,omitempty" description:"phase of the pv"`
+	ClaimPhase    string   `json:"claimPhase,omitempty" description:"phase of the pvc"`
+	Bound         bool     `json:"bound" description:"whether the pv and the pvc are bound to each other"`
+	Capacity      string   `json:"capacity,omitempty" description:"capacity of the pv, or of the pvc once bound"`
+	Requested     string   `json:"requested,omitempty" description:"storage requested by the pvc"`
+	StorageClass  string   `json:"storageClass,omitempty" description:"class of the storage"`
+	AccessModes   []string `json:"accessModes,omitempty" description:"modes of the access"`
+	ReclaimPolicy string   `json:"reclaimPolicy,omitempty" description:"what happens to the pv once released"`
+	Managed       bool     `json:"managed" description:"whether the pv was created by the service"`
+}
+
+type volumeInUseEntity struct {
+	Message string   `json:"message" description:"why the pvc cannot be deleted"`
+	Pods    []string `json:"pods" description:"pods which mount the pvc"`
+}
+
+func newVolumeEntity(pv *coreV1.PersistentVolume, pvc *coreV1.PersistentVolumeClaim) volumeEntity {
+	entity := volumeEntity{}
+	var modes []coreV1.PersistentVolumeAccessMode
+	if pvc != nil {
+		entity.PvcName = pvc.Name
+		entity.NameSpace = pvc.Namespace
+		entity.ClaimPhase = string(pvc.Status.Phase)
+		if requested, ok := pvc.Spec.Resources.Requests[coreV1.ResourceStorage]; ok {
+			entity.Requested = requested.String()
+		}
+		if capacity, ok := pvc.Status.Capacity[coreV1.ResourceStorage]; ok {
+			entity.Capacity = capacity.String()
+		}
+		if pvc.Spec.StorageClassName != nil {
+			entity.StorageClass = *pvc.Spec.StorageClassName
+		}
+		modes = pvc.Spec.AccessModes
+	}
+	if pv != nil {
+		entity.PvName = pv.Name
+		entity.Phase = string(pv.Status.Phase)
+		if capacity, ok := pv.Spec.Capacity[coreV1.ResourceStorage]; ok {
+			entity.Capacity = capacity.String()
+		}
+		entity.StorageClass = pv.Spec.StorageClassName
+		entity.ReclaimPolicy = string(pv.Spec.PersistentVolumeReclaimPolicy)
+		entity.Managed = pv.Labels[managedByLabel] == managedByValue
+		modes = pv.Spec.AccessModes
+		if pv.Spec.ClaimRef != nil && entity.PvcName == "" {
+			entity.PvcName = pv.Spec.ClaimRef.Name
+			entity.NameSpace = pv.Spec.ClaimRef.Namespace
+		}
+	}
+	for _, mode := range modes {
+		entity.AccessModes = append(entity.AccessModes, string(mode))
+	}
+	entity.Bound = pv != nil && pvc != nil && pvc.Status.Phase == coreV1.ClaimBound &&
+		pvc.Spec.VolumeName == pv.Name && pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.UID == pvc.UID
+	return entity
+}
+
+// GET http://localhost:8080/pv/?namespace=clustar-{ns}&managed=true
+//
+func (pvr persistentVolumeResource) findAllVolumes(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.QueryParameter("namespace")
+	managed := request.QueryParameter("managed") == "true"
+
+	options := metaV1.ListOptions{}
+	if managed {
+		options.LabelSelector = fmt.Sprintf("%s=%s", managedByLabel, managedByValue)
+	}
+	pvs, err := pvr.k8sClient.CoreV1().PersistentVolumes().List(options)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	pvcs, err := pvr.k8sClient.CoreV1().PersistentVolumeClaims(nameOfSpace).List(metaV1.ListOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+
+	claims := map[string]*coreV1.PersistentVolumeClaim{}
+	for i := range pvcs.Items {
+		claims[pvcs.Items[i].Namespace+"/"+pvcs.Items[i].Name] = &pvcs.Items[i]
+	}
+
+	list := []volumeEntity{}
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		var pvc *coreV1.PersistentVolumeClaim
+		if pv.Spec.ClaimRef != nil {
+			if nameOfSpace != "" && pv.Spec.ClaimRef.Namespace != nameOfSpace {
+				continue
+			}
+			key := pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name
+			pvc = claims[key]
+			delete(claims, key)
+		} else if nameOfSpace != "" {
+			continue
+		}
+		list = append(list, newVolumeEntity(pv, pvc))
+	}
+	// claims which are not bound yet, the managed ones only when they ask for a managed pv
+	for _, pvc := range claims {
+		if managed && pvc.Labels[managedByLabel] != managedByValue {
+			continue
+		}
+		list = append(list, newVolumeEntity(nil, pvc))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].NameSpace != list[j].NameSpace {
+			return list[i].NameSpace < list[j].NameSpace
+		}
+		if list[i].PvcName != list[j].PvcName {
+			return list[i].PvcName < list[j].PvcName
+		}
+		return list[i].PvName < list[j].PvName
+	})
+	response.WriteEntity(list)
+}
+
+// GET http://localhost:8080/pv/clustar-{ns}/{pvc}
+//
+func (pvr persistentVolumeResource) getClaimVolume(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfClaim := request.PathParameter("claim")
+
+	pvc, err := pvr.k8sClient.CoreV1().PersistentVolumeClaims(nameOfSpace).Get(nameOfClaim, metaV1.GetOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	var pv *coreV1.PersistentVolume
+	if pvc.Spec.VolumeName != "" {
+		pv, err = pvr.k8sClient.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metaV1.GetOptions{})
+		if err != nil && !k8sError.IsNotFound(err) {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		if err != nil {
+			pv = nil
+		}
+	}
+	response.WriteEntity(newVolumeEntity(pv, pvc))
+}
+
+// GET http://localhost:8080/pv/volumes/{pv}
+//
+func (pvr persistentVolumeResource) getVolume(request *restful.Request, response *restful.Response) {
+	nameOfVolume := request.PathParameter("volume")
+
+	pv, err := pvr.k8sClient.CoreV1().PersistentVolumes().Get(nameOfVolume, metaV1.GetOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	var pvc *coreV1.PersistentVolumeClaim
+	if pv.Spec.ClaimRef != nil {
+		pvc, err = pvr.k8sClient.CoreV1().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name, metaV1.GetOptions{})
+		if err != nil && !k8sError.IsNotFound(err) {
+			response.WriteError(statusOfError(err), err)
+			return
+		}
+		if err != nil {
+			pvc = nil
+		}
+	}
+	response.WriteEntity(newVolumeEntity(pv, pvc))
+}
+
+// DELETE http://localhost:8080/pv/clustar-{ns}/{pvc}?timeout=60
+//
+func (pvr persistentVolumeResource) removeClaimVolume(request *restful.Request, response *restful.Response) {
+	nameOfSpace := request.PathParameter("namespace")
+	nameOfClaim := request.PathParameter("claim")
+	if !strings.HasPrefix(nameOfSpace, pvr.selfDefineResourcePrefix) {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("namespace: %s is not self define resouce, cannot remove through service!", nameOfSpace)))
+		return
+	}
+	timeout, err := timeoutParameter(request, defaultReleaseTimeout)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	pvc, err := pvr.k8sClient.CoreV1().PersistentVolumeClaims(nameOfSpace).Get(nameOfClaim, metaV1.GetOptions{})
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if dataset := pvc.Labels[storage.DatasetLabel]; dataset != "" {
+		response.WriteError(http.StatusBadRequest, errors.New(
+			fmt.Sprintf("pvc %s/%s attaches dataset %s, detach it through DELETE /datasets/%s/tenants/%s", nameOfSpace, nameOfClaim, dataset, dataset, nameOfSpace)))
+		return
+	}
+
+	pods, err := podsMountingClaim(pvr.k8sClient, nameOfSpace, nameOfClaim)
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if len(pods) > 0 {
+		response.WriteHeaderAndEntity(http.StatusConflict, volumeInUseEntity{
+			Message: fmt.Sprintf("pvc %s/%s is mounted by running pods", nameOfSpace, nameOfClaim),
+			Pods:    pods,
+		})
+		return
+	}
+
+	err = pvr.k8sClient.CoreV1().PersistentVolumeClaims(nameOfSpace).Delete(nameOfClaim, &metaV1.DeleteOptions{})
+	if err != nil && !k8sError.IsNotFound(err) {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if pvc.Spec.VolumeName == "" {
+		response.Write([]byte("{\"status\":\"success\"}"))
+		return
+	}
+
+	// only the pvs created by the service are removed, any other pv is left to its reclaim policy
+	pv, err := pvr.k8sClient.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metaV1.GetOptions{})
+	if err != nil && !k8sError.IsNotFound(err) {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	if err != nil || pv.Labels[managedByLabel] != managedByValue || pv.Labels[storage.DatasetLabel] != "" {
+		response.Write([]byte("{\"status\":\"success\"}"))
+		return
+	}
+
+	// the pv is only removed once the claim is gone and the pv has been released
+	err = wait.PollImmediate(volumePollInterval, timeout, func() (bool, error) {
+		pv, err := pvr.k8sClient.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metaV1.GetOptions{})
+		if k8sError.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return pv.Status.Phase != coreV1.VolumeBound, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		response.WriteError(http.StatusGatewayTimeout, errors.New(
+			fmt.Sprintf("pvc %s/%s is deleted, but pv %s has not been released within %s", nameOfSpace, nameOfClaim, pvc.Spec.VolumeName, timeout)))
+		return
+	}
+	if err != nil {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+
+	err = pvr.k8sClient.CoreV1().PersistentVolumes().Delete(pvc.Spec.VolumeName, &metaV1.DeleteOptions{})
+	if err != nil && !k8sError.IsNotFound(err) {
+		response.WriteError(statusOfError(err), err)
+		return
+	}
+	response.Write([]byte("{\"status\":\"success\"}"))
+}
+
+// podsMountingClaim returns the pods of namespace which mount the claim and have not terminated
+func podsMountingClaim(k8sClient kubernetes.Interface, namespace, claim string) ([]string, error) {
+	pods, err := k8sClient.CoreV1().Pods(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == claim {
+				names = append(names, pod.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// timeoutParameter reads the timeout query parameter in seconds
+func timeoutParameter(request *restful.Request, defaultTimeout time.Duration) (time.Duration, error) {
+	t := request.QueryParameter("timeout")
+	if t == "" {
+		return defaultTimeout, nil
+	}
+	seconds, err := strconv.Atoi(t)
+	if err != nil || seconds <= 0 {
+		return 0, errors.New(fmt.Sprintf("invalid timeout: %s", t))
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// waitForClaimBound polls the claim until it is bound and returns it together with its volume,
+// wait.ErrWaitTimeout is returned with the last claim seen when it is still pending after timeout
+func waitForClaimBound(k8sClient kubernetes.Interface, namespace, name string, timeout time.Duration) (
+	*coreV1.PersistentVolumeClaim, *coreV1.PersistentVolume, error) {
+	var pvc *coreV1.PersistentVolumeClaim
+	err := wait.PollImmediate(volumePollInterval, timeout, func() (bool, error) {
+		var err error
+		pvc, err = k8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(name, metaV1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if pvc.Status.Phase == coreV1.ClaimLost {
+			return false, errors.New(fmt.Sprintf("pvc %s/%s lost its volume %s", namespace, name, pvc.Spec.VolumeName))
+		}
+		return pvc.Status.Phase == coreV1.ClaimBound, nil
+	})
+	if err != nil {
+		return pvc, nil, err
+	}
+	pv, err := k8sClient.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metaV1.GetOptions{})
+	if err != nil {
+		return pvc, nil, err
+	}
+	return pvc, pv, nil
+}
@@ -0,0 +1,76 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/starcloud-ai/kubeconfig/pkg/storage"
+	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func claimAndVolume(claim, volume string, volumeLabels map[string]string) []runtime.Object {
+	return []runtime.Object{
+		&coreV1.PersistentVolumeClaim{
+			ObjectMeta: metaV1.ObjectMeta{Name: claim, Namespace: "clustar-a", Labels: volumeLabels},
+			Spec:       coreV1.PersistentVolumeClaimSpec{VolumeName: volume},
+		},
+		&coreV1.PersistentVolume{
+			ObjectMeta: metaV1.ObjectMeta{Name: volume, Labels: volumeLabels},
+			Status:     coreV1.PersistentVolumeStatus{Phase: coreV1.VolumeReleased},
+		},
+	}
+}
+
+func TestRemoveClaimVolume(t *testing.T) {
+	var objects []runtime.Object
+	objects = append(objects, claimAndVolume("managed", "pv-managed", map[string]string{managedByLabel: managedByValue})...)
+	objects = append(objects, claimAndVolume("foreign", "pv-foreign", nil)...)
+	objects = append(objects, claimAndVolume("dataset-imagenet", "pv-imagenet",
+		map[string]string{managedByLabel: managedByValue, storage.DatasetLabel: "imagenet"})...)
+	client := fake.NewSimpleClientset(objects...)
+
+	container := restful.NewContainer()
+	container.Add(createPersistVolumeResource(client, "clustar-", nil, nil).WebService())
+	remove := func(claim string) int {
+		recorder := httptest.NewRecorder()
+		container.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/pv/clustar-a/"+claim, nil))
+		return recorder.Code
+	}
+	volumeExists := func(name string) bool {
+		_, err := client.CoreV1().PersistentVolumes().Get(name, metaV1.GetOptions{})
+		if err != nil && !k8sError.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	if code := remove("managed"); code != http.StatusOK {
+		t.Fatalf("remove managed claim: status = %d", code)
+	}
+	if volumeExists("pv-managed") {
+		t.Error("the pv created by the service should be deleted")
+	}
+
+	if code := remove("foreign"); code != http.StatusOK {
+		t.Fatalf("remove foreign claim: status = %d", code)
+	}
+	if !volumeExists("pv-foreign") {
+		t.Error("a pv not created by the service should be left to its reclaim policy")
+	}
+
+	if code := remove("dataset-imagenet"); code != http.StatusBadRequest {
+		t.Fatalf("remove dataset claim: status = %d", code)
+	}
+	if _, err := client.CoreV1().PersistentVolumeClaims("clustar-a").Get("dataset-imagenet", metaV1.GetOptions{}); err != nil {
+		t.Errorf("the dataset claim should be kept: %v", err)
+	}
+	if !volumeExists("pv-imagenet") {
+		t.Error("the dataset pv should be kept")
+	}
+}
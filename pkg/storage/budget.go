@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"fmt"
+
+	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// BudgetAnnotation records the storage budget of a tenant, tenants without it get the default budget
+	BudgetAnnotation = "clustar.ai/storage-budget"
+
+	// budgetQuotaName is the ResourceQuota enforcing the budget on every claim of the namespace
+	budgetQuotaName = "storage-budget"
+)
+
+// Budgets limits the storage a tenant may claim
+type Budgets struct {
+	K8sClient kubernetes.Interface
+	// Default applies to tenants without a budget of their own, nil means unlimited
+	Default *resource.Quantity
+}
+
+func NewBudgets(defaultBudget *resource.Quantity, k8sClient kubernetes.Interface) (budgets *Budgets) {
+	budgets = &Budgets{}
+	budgets.K8sClient = k8sClient
+	budgets.Default = defaultBudget
+	return
+}
+
+// Budget returns the budget of namespace, nil means unlimited
+func (budgets *Budgets) Budget(namespace string) (*resource.Quantity, error) {
+	ns, err := budgets.K8sClient.CoreV1().Namespaces().Get(namespace, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return budgets.BudgetOfNamespace(ns)
+}
+
+// BudgetOfNamespace returns the budget recorded on ns, or the default one
+func (budgets *Budgets) BudgetOfNamespace(ns *coreV1.Namespace) (*resource.Quantity, error) {
+	value, ok := ns.Annotations[BudgetAnnotation]
+	if !ok {
+		return budgets.Default, nil
+	}
+	budget, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage budget of namespace %s: %s", ns.Name, err)
+	}
+	return &budget, nil
+}
+
+// Used sums the storage requested by every claim inside namespace, which is what the requests.storage quota counts
+func (budgets *Budgets) Used(namespace string) (resource.Quantity, error) {
+	used := resource.Quantity{}
+	pvcs, err := budgets.K8sClient.CoreV1().PersistentVolumeClaims(namespace).List(metaV1.ListOptions{})
+	if err != nil {
+		return used, err
+	}
+	for _, pvc := range pvcs.Items {
+		if requested, ok := pvc.Spec.Resources.Requests[coreV1.ResourceStorage]; ok {
+			used.Add(requested)
+		}
+	}
+	return used, nil
+}
+
+// Check tells if namespace may claim requested more storage. It is answered before anything is created,
+// the requests.storage quota brought in sync here is what enforces the budget against concurrent claims.
+func (budgets *Budgets) Check(namespace string, requested resource.Quantity) error {
+	budget, err := budgets.Budget(namespace)
+	if err != nil {
+		return err
+	}
+	if _, err := budgets.syncQuota(namespace, budget); err != nil || budget == nil {
+		return err
+	}
+	used, err := budgets.Used(namespace)
+	if err != nil {
+		return err
+	}
+	total := used.DeepCopy()
+	total.Add(requested)
+	if total.Cmp(*budget) > 0 {
+		return &BudgetExceededError{Namespace: namespace, Budget: budget.String(), Used: used.String(), Requested: requested.String()}
+	}
+	return nil
+}
+
+// SetBudget records the budget of namespace and keeps its requests.storage quota in sync,
+// nil falls back to the default budget
+func (budgets *Budgets) SetBudget(namespace string, budget *resource.Quantity) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ns, err := budgets.K8sClient.CoreV1().Namespaces().Get(namespace, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if budget == nil {
+			delete(ns.Annotations, BudgetAnnotation)
+		} else {
+			if ns.Annotations == nil {
+				ns.Annotations = map[string]string{}
+			}
+			ns.Annotations[BudgetAnnotation] = budget.String()
+		}
+		_, err = budgets.K8sClient.CoreV1().Namespaces().Update(ns)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if budget == nil {
+		budget = budgets.Default
+	}
+	_, err = budgets.syncQuota(namespace, budget)
+	return err
+}
+
+// SyncQuota brings the requests.storage quota of namespace in line with its budget, the default one included.
+// It is called when a tenant is provisioned or reconciled, and tells if the quota has been changed.
+func (budgets *Budgets) SyncQuota(namespace string) (bool, error) {
+	budget, err := budgets.Budget(namespace)
+	if err != nil {
+		return false, err
+	}
+	return budgets.syncQuota(namespace, budget)
+}
+
+// syncQuota makes the requests.storage quota of namespace match budget, or removes it when unlimited
+func (budgets *Budgets) syncQuota(namespace string, budget *resource.Quantity) (bool, error) {
+	quotas := budgets.K8sClient.CoreV1().ResourceQuotas(namespace)
+	if budget == nil {
+		err := quotas.Delete(budgetQuotaName, &metaV1.DeleteOptions{})
+		if k8sError.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		quota, err := quotas.Get(budgetQuotaName, metaV1.GetOptions{})
+		if k8sError.IsNotFound(err) {
+			_, err = quotas.Create(&coreV1.ResourceQuota{
+				ObjectMeta: metaV1.ObjectMeta{Name: budgetQuotaName, Namespace: namespace},
+				Spec: coreV1.ResourceQuotaSpec{
+					Hard: coreV1.ResourceList{coreV1.ResourceRequestsStorage: *budget},
+				},
+			})
+			changed = err == nil
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if current, ok := quota.Spec.Hard[coreV1.ResourceRequestsStorage]; ok && current.Cmp(*budget) == 0 {
+			return nil
+		}
+		if quota.Spec.Hard == nil {
+			quota.Spec.Hard = coreV1.ResourceList{}
+		}
+		quota.Spec.Hard[coreV1.ResourceRequestsStorage] = *budget
+		_, err = quotas.Update(quota)
+		changed = err == nil
+		return err
+	})
+	return changed, err
+}
+
+// BudgetExceededError is returned when a claim would take a tenant beyond its storage budget
+type BudgetExceededError struct {
+	Namespace string
+	Budget    string
+	Used      string
+	Requested string
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("namespace %s requests %s of storage, %s of its budget %s are used already",
+		e.Namespace, e.Requested, e.Used, e.Budget)
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSyncQuota(t *testing.T) {
+	defaultBudget := resource.MustParse("10Gi")
+	client := fake.NewSimpleClientset(
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-a"}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "clustar-b",
+			Annotations: map[string]string{BudgetAnnotation: "1Gi"}}},
+	)
+	budgets := NewBudgets(&defaultBudget, client)
+	tests := []struct {
+		namespace string
+		hard      string
+		changed   bool
+	}{
+		{namespace: "clustar-a", hard: "10Gi", changed: true},
+		{namespace: "clustar-a", hard: "10Gi"},
+		{namespace: "clustar-b", hard: "1Gi", changed: true},
+	}
+	for _, test := range tests {
+		changed, err := budgets.SyncQuota(test.namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != test.changed {
+			t.Errorf("SyncQuota(%s) changed = %v, want %v", test.namespace, changed, test.changed)
+		}
+		quota, err := client.CoreV1().ResourceQuotas(test.namespace).Get(budgetQuotaName, metaV1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hard := quota.Spec.Hard[coreV1.ResourceRequestsStorage]
+		if hard.Cmp(resource.MustParse(test.hard)) != 0 {
+			t.Errorf("quota of %s = %s, want %s", test.namespace, hard.String(), test.hard)
+		}
+	}
+}
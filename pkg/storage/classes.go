@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	storageV1 "k8s.io/api/storage/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultClassAnnotation marks the class claims without a class are provisioned from
+const defaultClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// Classes restricts the StorageClasses tenants may provision volumes from
+type Classes struct {
+	K8sClient kubernetes.Interface
+	// Allowed names the classes tenants may use, empty allows every class
+	Allowed []string
+}
+
+func NewClasses(allowed []string, k8sClient kubernetes.Interface) (classes *Classes) {
+	classes = &Classes{}
+	classes.K8sClient = k8sClient
+	classes.Allowed = allowed
+	return
+}
+
+// IsAllowed tells if tenants may provision volumes from the class name
+func (classes *Classes) IsAllowed(name string) bool {
+	if len(classes.Allowed) == 0 {
+		return true
+	}
+	for _, allowed := range classes.Allowed {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns every StorageClass of the cluster ordered by name
+func (classes *Classes) List() ([]storageV1.StorageClass, error) {
+	list, err := classes.K8sClient.StorageV1().StorageClasses().List(metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list.Items, nil
+}
+
+// Get returns the class name if tenants may use it
+func (classes *Classes) Get(name string) (*storageV1.StorageClass, error) {
+	if !classes.IsAllowed(name) {
+		return nil, &ClassNotAllowedError{Name: name}
+	}
+	return classes.K8sClient.StorageV1().StorageClasses().Get(name, metaV1.GetOptions{})
+}
+
+// IsDefaultClass tells if claims without a class are provisioned from class
+func IsDefaultClass(class *storageV1.StorageClass) bool {
+	return class.Annotations[defaultClassAnnotation] == "true"
+}
+
+// BindsImmediately tells if claims of class are bound as soon as they are created,
+// otherwise they stay pending until a pod using them is scheduled
+func BindsImmediately(class *storageV1.StorageClass) bool {
+	return class.VolumeBindingMode == nil || *class.VolumeBindingMode == storageV1.VolumeBindingImmediate
+}
+
+// ClassNotAllowedError is returned for a StorageClass tenants may not use
+type ClassNotAllowedError struct {
+	Name string
+}
+
+func (e *ClassNotAllowedError) Error() string {
+	return fmt.Sprintf("storage class %s is not allowed for tenants", e.Name)
+}
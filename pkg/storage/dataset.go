@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	coreV1 "k8s.io/api/core/v1"
+	k8sError "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DatasetLabel marks the ConfigMap registering a dataset and the volumes attaching it, its value is the dataset name
+	DatasetLabel = "clustar.ai/dataset"
+	// DatasetTenantLabel is the namespace a dataset volume has been attached to
+	DatasetTenantLabel = "clustar.ai/dataset-tenant"
+
+	datasetSourceKey      = "source"
+	datasetSizeKey        = "size"
+	datasetDescriptionKey = "description"
+)
+
+// datasetClaimRequest is all a dataset claim requests, the claim is pre-bound to its volume so it only has to fit
+// the capacity, and the data shared by every tenant must not take the storage budget of each of them
+var datasetClaimRequest = resource.MustParse("1Mi")
+
+// Dataset is data registered once and mounted read only into many tenants
+type Dataset struct {
+	Name        string  `json:"name" description:"name of the dataset"`
+	Description string  `json:"description,omitempty" description:"what the dataset holds"`
+	Source      *Source `json:"source" description:"backend holding the data of the dataset"`
+	Size        string  `json:"size" description:"quantity of the storage the dataset takes"`
+}
+
+// Attachment is the volume a dataset has been attached to a tenant with
+type Attachment struct {
+	Dataset   string `json:"dataset" description:"name of the dataset"`
+	Namespace string `json:"namespace" description:"namespace of the tenant"`
+	PvName    string `json:"pvName" description:"name of the pv"`
+	PvcName   string `json:"pvcName" description:"name of the pvc inside the namespace"`
+	Phase     string `json:"phase,omitempty" description:"phase of the pv"`
+}
+
+// Validate checks the dataset can be registered, a PersistentVolume can only be bound once so every tenant gets
+// its own volume on the same source. CSI volumes are refused as one volume handle must not be used by several of them,
+// hostpath volumes as they cannot be mounted read only.
+func (dataset *Dataset) Validate() error {
+	if errs := validation.IsDNS1123Label(dataset.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid dataset name %q: %v", dataset.Name, errs)
+	}
+	if dataset.Source == nil {
+		return fmt.Errorf("source of dataset %s must not be empty", dataset.Name)
+	}
+	// the dataset is claimed in every tenant it is attached to, so it cannot refer to objects of one namespace
+	if err := dataset.Source.Validate(""); err != nil {
+		return err
+	}
+	if dataset.Source.CSI != nil {
+		return fmt.Errorf("csi volumes cannot be shared as a dataset")
+	}
+	if dataset.Source.HostPath != nil {
+		return fmt.Errorf("hostpath volumes cannot be shared as a dataset")
+	}
+	if _, err := resource.ParseQuantity(dataset.Size); err != nil {
+		return fmt.Errorf("invalid dataset size %q: %s", dataset.Size, err)
+	}
+	return nil
+}
+
+// Datasets keeps the registered datasets as ConfigMaps of one namespace,
+// the tenants of a dataset are found through the labels of its volumes
+type Datasets struct {
+	K8sClient kubernetes.Interface
+	Namespace string
+}
+
+func NewDatasets(namespace string, k8sClient kubernetes.Interface) (datasets *Datasets) {
+	datasets = &Datasets{}
+	datasets.K8sClient = k8sClient
+	datasets.Namespace = namespace
+	return
+}
+
+// Register records a new dataset, names are never reused while registered
+func (datasets *Datasets) Register(dataset *Dataset) error {
+	if err := dataset.Validate(); err != nil {
+		return err
+	}
+	source, err := json.Marshal(dataset.Source)
+	if err != nil {
+		return err
+	}
+	_, err = datasets.K8sClient.CoreV1().ConfigMaps(datasets.Namespace).Create(&coreV1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   dataset.Name,
+			Labels: map[string]string{DatasetLabel: dataset.Name},
+		},
+		Data: map[string]string{
+			datasetSourceKey:      string(source),
+			datasetSizeKey:        dataset.Size,
+			datasetDescriptionKey: dataset.Description,
+		},
+	})
+	return err
+}
+
+func (datasets *Datasets) Get(name string) (*Dataset, error) {
+	configMap, err := datasets.K8sClient.CoreV1().ConfigMaps(datasets.Namespace).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if configMap.Labels[DatasetLabel] != name {
+		return nil, k8sError.NewNotFound(coreV1.Resource("datasets"), name)
+	}
+	return datasetOfConfigMap(configMap)
+}
+
+// List returns every registered dataset ordered by name
+func (datasets *Datasets) List() ([]Dataset, error) {
+	configMaps, err := datasets.K8sClient.CoreV1().ConfigMaps(datasets.Namespace).List(metaV1.ListOptions{LabelSelector: DatasetLabel})
+	if err != nil {
+		return nil, err
+	}
+	var list []Dataset
+	for i := range configMaps.Items {
+		dataset, err := datasetOfConfigMap(&configMaps.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *dataset)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Unregister removes a dataset, it is refused while the dataset is attached to any tenant
+func (datasets *Datasets) Unregister(name string) error {
+	if _, err := datasets.Get(name); err != nil {
+		return err
+	}
+	attachments, err := datasets.Attachments(name)
+	if err != nil {
+		return err
+	}
+	if len(attachments) > 0 {
+		var tenants []string
+		for _, attachment := range attachments {
+			tenants = append(tenants, attachment.Namespace)
+		}
+		return &AttachedError{Dataset: name, Namespaces: tenants}
+	}
+	return datasets.K8sClient.CoreV1().ConfigMaps(datasets.Namespace).Delete(name, &metaV1.DeleteOptions{})
+}
+
+// Attachments returns the tenants the dataset name has been attached to, ordered by namespace
+func (datasets *Datasets) Attachments(name string) ([]Attachment, error) {
+	pvs, err := datasets.K8sClient.CoreV1().PersistentVolumes().List(metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", DatasetLabel, name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var list []Attachment
+	for i := range pvs.Items {
+		list = append(list, attachmentOfVolume(&pvs.Items[i]))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Namespace < list[j].Namespace })
+	return list, nil
+}
+
+// Attach creates the volume and claim of the dataset inside namespace, forced read only.
+// The volume is bound to the claim up front so no other claim can take it, attaching again returns the existing pair.
+func (datasets *Datasets) Attach(name, namespace string) (*Attachment, error) {
+	dataset, err := datasets.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	size, err := resource.ParseQuantity(dataset.Size)
+	if err != nil {
+		return nil, err
+	}
+	pvName, pvcName := VolumeNameOfDataset(name, namespace), ClaimNameOfDataset(name)
+	labels := map[string]string{DatasetLabel: name, DatasetTenantLabel: namespace}
+	accessModes := []coreV1.PersistentVolumeAccessMode{coreV1.ReadOnlyMany}
+	noClass := ""
+	request := datasetClaimRequest
+	if size.Cmp(request) < 0 {
+		request = size
+	}
+
+	// volumes attached before their names were hashed keep their name
+	existing, err := datasets.attachedVolume(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		pvName = existing.Name
+	}
+
+	volumeSource, nodeAffinity := dataset.Source.VolumeSource(namespace)
+	readOnly(&volumeSource)
+	pv := &coreV1.PersistentVolume{
+		ObjectMeta: metaV1.ObjectMeta{Name: pvName, Labels: labels},
+		Spec: coreV1.PersistentVolumeSpec{
+			AccessModes:            accessModes,
+			Capacity:               coreV1.ResourceList{coreV1.ResourceStorage: size},
+			PersistentVolumeSource: volumeSource,
+			NodeAffinity:           nodeAffinity,
+			// the data belongs to the dataset, detaching a tenant must never remove it
+			PersistentVolumeReclaimPolicy: coreV1.PersistentVolumeReclaimRetain,
+			ClaimRef: &coreV1.ObjectReference{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+				Namespace:  namespace,
+				Name:       pvcName,
+			},
+		},
+	}
+	if existing != nil {
+		pv = existing
+	} else {
+		pv, err = datasets.K8sClient.CoreV1().PersistentVolumes().Create(pv)
+		if k8sError.IsAlreadyExists(err) {
+			pv, err = datasets.K8sClient.CoreV1().PersistentVolumes().Get(pvName, metaV1.GetOptions{})
+			if err == nil && (pv.Labels[DatasetLabel] != name || pv.Labels[DatasetTenantLabel] != namespace) {
+				err = fmt.Errorf("pv %s already exists and does not attach dataset %s to %s", pvName, name, namespace)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pvc := &coreV1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{Name: pvcName, Namespace: namespace, Labels: labels},
+		Spec: coreV1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			Resources:        coreV1.ResourceRequirements{Requests: coreV1.ResourceList{coreV1.ResourceStorage: request}},
+			StorageClassName: &noClass,
+			VolumeName:       pvName,
+		},
+	}
+	_, err = datasets.K8sClient.CoreV1().PersistentVolumeClaims(namespace).Create(pvc)
+	if k8sError.IsAlreadyExists(err) {
+		pvc, err = datasets.K8sClient.CoreV1().PersistentVolumeClaims(namespace).Get(pvcName, metaV1.GetOptions{})
+		if err == nil && (pvc.Labels[DatasetLabel] != name || pvc.Spec.VolumeName != pvName) {
+			err = fmt.Errorf("pvc %s/%s already exists and does not belong to dataset %s", namespace, pvcName, name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	attachment := attachmentOfVolume(pv)
+	return &attachment, nil
+}
+
+// Detach removes the claim and volume of the dataset from namespace, the data itself is kept
+func (datasets *Datasets) Detach(name, namespace string) error {
+	pv, err := datasets.attachedVolume(name, namespace)
+	if err != nil {
+		return err
+	}
+	if pv == nil {
+		return k8sError.NewNotFound(coreV1.Resource("persistentvolumes"), VolumeNameOfDataset(name, namespace))
+	}
+	pvName := pv.Name
+	err = datasets.K8sClient.CoreV1().PersistentVolumeClaims(namespace).Delete(ClaimNameOfDataset(name), &metaV1.DeleteOptions{})
+	if err != nil && !k8sError.IsNotFound(err) {
+		return err
+	}
+	err = datasets.K8sClient.CoreV1().PersistentVolumes().Delete(pvName, &metaV1.DeleteOptions{})
+	if err != nil && !k8sError.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// attachedVolume finds the volume attaching the dataset to namespace through its labels, nil when it is not attached
+func (datasets *Datasets) attachedVolume(name, namespace string) (*coreV1.PersistentVolume, error) {
+	pvs, err := datasets.K8sClient.CoreV1().PersistentVolumes().List(metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s,%s=%s", DatasetLabel, name, DatasetTenantLabel, namespace),
+	})
+	if err != nil || len(pvs.Items) == 0 {
+		return nil, err
+	}
+	return &pvs.Items[0], nil
+}
+
+// VolumeNameOfDataset returns the name of the volume attaching the dataset to namespace. Both names may contain
+// dashes, so they are hashed together to keep the names of different pairs apart.
+func VolumeNameOfDataset(name, namespace string) string {
+	sum := sha256.Sum256([]byte(name + "/" + namespace))
+	return fmt.Sprintf("dataset-%s-%s", name, hex.EncodeToString(sum[:])[:16])
+}
+
+// ClaimNameOfDataset returns the name of the claim a tenant mounts the dataset through
+func ClaimNameOfDataset(name string) string {
+	return fmt.Sprintf("dataset-%s", name)
+}
+
+func datasetOfConfigMap(configMap *coreV1.ConfigMap) (*Dataset, error) {
+	dataset := &Dataset{
+		Name:        configMap.Name,
+		Description: configMap.Data[datasetDescriptionKey],
+		Size:        configMap.Data[datasetSizeKey],
+		Source:      &Source{},
+	}
+	if err := json.Unmarshal([]byte(configMap.Data[datasetSourceKey]), dataset.Source); err != nil {
+		return nil, fmt.Errorf("invalid source of dataset %s: %s", configMap.Name, err)
+	}
+	return dataset, nil
+}
+
+func attachmentOfVolume(pv *coreV1.PersistentVolume) Attachment {
+	attachment := Attachment{
+		Dataset:   pv.Labels[DatasetLabel],
+		Namespace: pv.Labels[DatasetTenantLabel],
+		PvName:    pv.Name,
+		Phase:     string(pv.Status.Phase),
+	}
+	if pv.Spec.ClaimRef != nil {
+		attachment.PvcName = pv.Spec.ClaimRef.Name
+	}
+	return attachment
+}
+
+// readOnly makes the mounts of the volume read only where the backend supports it
+func readOnly(source *coreV1.PersistentVolumeSource) {
+	if source.NFS != nil {
+		source.NFS.ReadOnly = true
+	}
+	if source.CephFS != nil {
+		source.CephFS.ReadOnly = true
+	}
+}
+
+// AttachedError is returned when a dataset still attached to tenants is unregistered
+type AttachedError struct {
+	Dataset    string
+	Namespaces []string
+}
+
+func (e *AttachedError) Error() string {
+	return fmt.Sprintf("dataset %s is still attached to namespaces %v", e.Dataset, e.Namespaces)
+}
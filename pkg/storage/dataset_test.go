@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAttachDatasetsWithDashes(t *testing.T) {
+	datasets := NewDatasets("workshop", fake.NewSimpleClientset())
+	for _, name := range []string{"a", "a-b"} {
+		err := datasets.Register(&Dataset{
+			Name:   name,
+			Size:   "1Gi",
+			Source: &Source{Type: NFS, NFS: &NFSParameters{Server: "10.0.0.1", Path: "/exports/" + name}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// both pairs were called dataset-a-b-c before the names were hashed
+	first, err := datasets.Attach("a-b", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := datasets.Attach("a", "b-c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.PvName == second.PvName {
+		t.Errorf("both attachments use pv %s", first.PvName)
+	}
+	if second.Dataset != "a" || second.Namespace != "b-c" {
+		t.Errorf("attachment = %+v, want dataset a in b-c", second)
+	}
+
+	again, err := datasets.Attach("a", "b-c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.PvName != second.PvName {
+		t.Errorf("attaching again uses pv %s instead of %s", again.PvName, second.PvName)
+	}
+	if err := datasets.Detach("a-b", "c"); err != nil {
+		t.Fatal(err)
+	}
+	attachments, err := datasets.Attachments("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attachments) != 1 || attachments[0].Namespace != "b-c" {
+		t.Errorf("attachments of a = %+v, want the one of b-c", attachments)
+	}
+}
+
+func TestHostPathDatasetRefused(t *testing.T) {
+	AllowedHostPaths = []string{"/data"}
+	defer func() { AllowedHostPaths = nil }()
+	dataset := &Dataset{
+		Name:   "local",
+		Size:   "1Gi",
+		Source: &Source{Type: HostPath, HostPath: &HostPathParameters{Path: "/data/local", Nodes: []string{"node-1"}}},
+	}
+	if err := dataset.Validate(); err == nil {
+		t.Error("hostpath dataset accepted, it cannot be mounted read only")
+	}
+}
+
+func TestAttachedClaimRequestsNominalSize(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	datasets := NewDatasets("workshop", client)
+	for name, size := range map[string]string{"large": "1Ti", "small": "512Ki"} {
+		err := datasets.Register(&Dataset{
+			Name:   name,
+			Size:   size,
+			Source: &Source{Type: NFS, NFS: &NFSParameters{Server: "10.0.0.1", Path: "/exports/" + name}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := datasets.Attach(name, "tenant"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]string{"large": "1Mi", "small": "512Ki"} {
+		pvc, err := client.CoreV1().PersistentVolumeClaims("tenant").Get(ClaimNameOfDataset(name), metaV1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if request := pvc.Spec.Resources.Requests[coreV1.ResourceStorage]; request.String() != want {
+			t.Errorf("claim of %s requests %s, want %s", name, request.String(), want)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	NFS      = "nfs"
+	CephFS   = "cephfs"
+	HostPath = "hostpath"
+	CSI      = "csi"
+
+	// hostnameLabel is set by the kubelet on every node, hostPath volumes are pinned to nodes through it
+	hostnameLabel = "kubernetes.io/hostname"
+)
+
+// AllowedHostPaths are the directories of the nodes hostpath volumes may be made of, together with
+// everything below them. Tenants are root on the path they mount, so without any hostpath volumes are refused.
+var AllowedHostPaths []string
+
+// Source describes where the data of a volume lives, Type picks the backend and only its parameters may be set
+type Source struct {
+	Type     string              `json:"type" description:"backend of the volume: nfs, cephfs, hostpath or csi"`
+	NFS      *NFSParameters      `json:"nfs,omitempty" description:"parameters of the nfs backend"`
+	CephFS   *CephFSParameters   `json:"cephfs,omitempty" description:"parameters of the cephfs backend"`
+	HostPath *HostPathParameters `json:"hostpath,omitempty" description:"parameters of the hostpath backend"`
+	CSI      *CSIParameters      `json:"csi,omitempty" description:"parameters of the csi backend"`
+}
+
+type NFSParameters struct {
+	Server string `json:"server" description:"ip or hostname of the nfs server"`
+	Path   string `json:"path" description:"exported path on the nfs server"`
+}
+
+type CephFSParameters struct {
+	Monitors        []string `json:"monitors" description:"addresses of the ceph monitors"`
+	Path            string   `json:"path,omitempty" description:"path inside the ceph filesystem, / by default"`
+	User            string   `json:"user,omitempty" description:"rados user, admin by default"`
+	SecretName      string   `json:"secretName,omitempty" description:"name of the secret holding the key of the user"`
+	SecretNamespace string   `json:"secretNamespace,omitempty" description:"namespace of the secret holding the key of the user"`
+}
+
+type HostPathParameters struct {
+	Path  string   `json:"path" description:"absolute path on the nodes"`
+	Type  string   `json:"type,omitempty" description:"type of the path, as for a hostPath volume"`
+	Nodes []string `json:"nodes" description:"hostnames of the nodes which have the path, pods are only scheduled there"`
+}
+
+type CSIParameters struct {
+	Driver           string            `json:"driver" description:"name of the csi driver"`
+	VolumeHandle     string            `json:"volumeHandle" description:"identifier of the volume inside the driver"`
+	FSType           string            `json:"fsType,omitempty" description:"filesystem type to mount"`
+	VolumeAttributes map[string]string `json:"volumeAttributes,omitempty" description:"attributes passed to the driver"`
+}
+
+// Parameter documents one parameter of a backend on the discovery endpoint
+type Parameter struct {
+	Name        string `json:"name" description:"name of the parameter"`
+	Required    bool   `json:"required" description:"whether the parameter must be given"`
+	Description string `json:"description" description:"meaning of the parameter"`
+}
+
+// Backend turns a Source of its type into the source of a PersistentVolume
+type Backend interface {
+	Type() string
+	Description() string
+	Parameters() []Parameter
+	// Validate and VolumeSource get the namespace of the claim, objects the volume refers to must live there
+	Validate(source *Source, namespace string) error
+	VolumeSource(source *Source, namespace string) (coreV1.PersistentVolumeSource, *coreV1.VolumeNodeAffinity)
+}
+
+var backends = map[string]Backend{
+	NFS:      nfsBackend{},
+	CephFS:   cephFSBackend{},
+	HostPath: hostPathBackend{},
+	CSI:      csiBackend{},
+}
+
+// Backends returns every backend ordered by type
+func Backends() []Backend {
+	var list []Backend
+	for _, backend := range backends {
+		list = append(list, backend)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Type() < list[j].Type()
+	})
+	return list
+}
+
+// Lookup returns the backend of the source type
+func Lookup(sourceType string) (Backend, error) {
+	backend, ok := backends[strings.ToLower(sourceType)]
+	if !ok {
+		var types []string
+		for _, each := range Backends() {
+			types = append(types, each.Type())
+		}
+		return nil, fmt.Errorf("unknown volume source type %q, must be one of %s", sourceType, strings.Join(types, ", "))
+	}
+	return backend, nil
+}
+
+// Validate checks the source of a claim in namespace against its backend, parameters of other backends are refused.
+// An empty namespace stands for the claims of any namespace.
+func (source *Source) Validate(namespace string) error {
+	backend, err := Lookup(source.Type)
+	if err != nil {
+		return err
+	}
+	given := map[string]bool{
+		NFS:      source.NFS != nil,
+		CephFS:   source.CephFS != nil,
+		HostPath: source.HostPath != nil,
+		CSI:      source.CSI != nil,
+	}
+	for sourceType, ok := range given {
+		if ok && sourceType != backend.Type() {
+			return fmt.Errorf("parameters of %s given for a volume source of type %s", sourceType, backend.Type())
+		}
+	}
+	if !given[backend.Type()] {
+		return fmt.Errorf("parameters of %s are missing", backend.Type())
+	}
+	return backend.Validate(source, namespace)
+}
+
+// VolumeSource returns the source and node affinity of a PersistentVolume claimed in namespace,
+// the source must have been validated
+func (source *Source) VolumeSource(namespace string) (coreV1.PersistentVolumeSource, *coreV1.VolumeNodeAffinity) {
+	backend, _ := Lookup(source.Type)
+	return backend.VolumeSource(source, namespace)
+}
+
+type nfsBackend struct{}
+
+func (nfsBackend) Type() string { return NFS }
+
+func (nfsBackend) Description() string { return "a path exported by an nfs server" }
+
+func (nfsBackend) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "server", Required: true, Description: "ip or hostname of the nfs server"},
+		{Name: "path", Required: true, Description: "exported path on the nfs server"},
+	}
+}
+
+func (nfsBackend) Validate(source *Source, namespace string) error {
+	if source.NFS.Server == "" {
+		return fmt.Errorf("nfs server must not be empty")
+	}
+	if !path.IsAbs(source.NFS.Path) {
+		return fmt.Errorf("nfs path must be absolute: %q", source.NFS.Path)
+	}
+	return nil
+}
+
+func (nfsBackend) VolumeSource(source *Source, namespace string) (coreV1.PersistentVolumeSource, *coreV1.VolumeNodeAffinity) {
+	return coreV1.PersistentVolumeSource{
+		NFS: &coreV1.NFSVolumeSource{Server: source.NFS.Server, Path: source.NFS.Path},
+	}, nil
+}
+
+type cephFSBackend struct{}
+
+func (cephFSBackend) Type() string { return CephFS }
+
+func (cephFSBackend) Description() string { return "a path inside a ceph filesystem" }
+
+func (cephFSBackend) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "monitors", Required: true, Description: "addresses of the ceph monitors"},
+		{Name: "path", Description: "path inside the ceph filesystem, / by default"},
+		{Name: "user", Description: "rados user, admin by default"},
+		{Name: "secretName", Description: "name of the secret holding the key of the user"},
+		{Name: "secretNamespace", Description: "namespace of the secret, only the namespace of the claim is allowed"},
+	}
+}
+
+func (cephFSBackend) Validate(source *Source, namespace string) error {
+	if len(source.CephFS.Monitors) == 0 {
+		return fmt.Errorf("cephfs monitors must not be empty")
+	}
+	for _, monitor := range source.CephFS.Monitors {
+		if monitor == "" {
+			return fmt.Errorf("cephfs monitor must not be empty")
+		}
+	}
+	if source.CephFS.Path != "" && !path.IsAbs(source.CephFS.Path) {
+		return fmt.Errorf("cephfs path must be absolute: %q", source.CephFS.Path)
+	}
+	if source.CephFS.SecretName == "" && source.CephFS.SecretNamespace != "" {
+		return fmt.Errorf("cephfs secret namespace given without a secret name")
+	}
+	// the secret is read on behalf of the tenant, it must not come from another namespace
+	if source.CephFS.SecretNamespace != "" && source.CephFS.SecretNamespace != namespace {
+		return fmt.Errorf("cephfs secret must be in the namespace of the claim, not in %q", source.CephFS.SecretNamespace)
+	}
+	return nil
+}
+
+func (cephFSBackend) VolumeSource(source *Source, namespace string) (coreV1.PersistentVolumeSource, *coreV1.VolumeNodeAffinity) {
+	cephFS := &coreV1.CephFSPersistentVolumeSource{
+		Monitors: source.CephFS.Monitors,
+		Path:     source.CephFS.Path,
+		User:     source.CephFS.User,
+	}
+	if source.CephFS.SecretName != "" {
+		cephFS.SecretRef = &coreV1.SecretReference{
+			Name:      source.CephFS.SecretName,
+			Namespace: namespace,
+		}
+	}
+	return coreV1.PersistentVolumeSource{CephFS: cephFS}, nil
+}
+
+type hostPathBackend struct{}
+
+func (hostPathBackend) Type() string { return HostPath }
+
+func (hostPathBackend) Description() string {
+	return "a local path of some nodes, pods using the volume are only scheduled onto them"
+}
+
+func (hostPathBackend) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "path", Required: true, Description: "absolute path on the nodes, below one of the allowed host paths"},
+		{Name: "type", Description: "type of the path, as for a hostPath volume"},
+		{Name: "nodes", Required: true, Description: "hostnames of the nodes which have the path"},
+	}
+}
+
+var hostPathTypes = map[coreV1.HostPathType]bool{
+	coreV1.HostPathUnset:             true,
+	coreV1.HostPathDirectoryOrCreate: true,
+	coreV1.HostPathDirectory:         true,
+	coreV1.HostPathFileOrCreate:      true,
+	coreV1.HostPathFile:              true,
+	coreV1.HostPathSocket:            true,
+	coreV1.HostPathCharDev:           true,
+	coreV1.HostPathBlockDev:          true,
+}
+
+func (hostPathBackend) Validate(source *Source, namespace string) error {
+	if !path.IsAbs(source.HostPath.Path) {
+		return fmt.Errorf("hostpath path must be absolute: %q", source.HostPath.Path)
+	}
+	if !isAllowedHostPath(source.HostPath.Path) {
+		return fmt.Errorf("hostpath path %q is not below any of the allowed host paths %s",
+			source.HostPath.Path, strings.Join(AllowedHostPaths, ", "))
+	}
+	if !hostPathTypes[coreV1.HostPathType(source.HostPath.Type)] {
+		return fmt.Errorf("unknown hostpath type %q", source.HostPath.Type)
+	}
+	// without affinity the pods would see a different directory on every node
+	if len(source.HostPath.Nodes) == 0 {
+		return fmt.Errorf("hostpath nodes must not be empty")
+	}
+	for _, node := range source.HostPath.Nodes {
+		if errs := validation.IsDNS1123Subdomain(node); len(errs) > 0 {
+			return fmt.Errorf("invalid hostpath node %q: %s", node, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// isAllowedHostPath tells if hostPath is one of the allowed host paths or below one of them
+func isAllowedHostPath(hostPath string) bool {
+	hostPath = path.Clean(hostPath)
+	for _, allowed := range AllowedHostPaths {
+		allowed = path.Clean(allowed)
+		if hostPath == allowed || strings.HasPrefix(hostPath, strings.TrimSuffix(allowed, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (hostPathBackend) VolumeSource(source *Source, namespace string) (coreV1.PersistentVolumeSource, *coreV1.VolumeNodeAffinity) {
+	hostPathType := coreV1.HostPathType(source.HostPath.Type)
+	return coreV1.PersistentVolumeSource{
+		HostPath: &coreV1.HostPathVolumeSource{Path: path.Clean(source.HostPath.Path), Type: &hostPathType},
+	}, &coreV1.VolumeNodeAffinity{
+		Required: &coreV1.NodeSelector{
+			NodeSelectorTerms: []coreV1.NodeSelectorTerm{{
+				MatchExpressions: []coreV1.NodeSelectorRequirement{{
+					Key:      hostnameLabel,
+					Operator: coreV1.NodeSelectorOpIn,
+					Values:   source.HostPath.Nodes,
+				}},
+			}},
+		},
+	}
+}
+
+type csiBackend struct{}
+
+func (csiBackend) Type() string { return CSI }
+
+func (csiBackend) Description() string { return "a volume which already exists inside a csi driver" }
+
+func (csiBackend) Parameters() []Parameter {
+	return []Parameter{
+		{Name: "driver", Required: true, Description: "name of the csi driver"},
+		{Name: "volumeHandle", Required: true, Description: "identifier of the volume inside the driver"},
+		{Name: "fsType", Description: "filesystem type to mount"},
+		{Name: "volumeAttributes", Description: "attributes passed to the driver"},
+	}
+}
+
+func (csiBackend) Validate(source *Source, namespace string) error {
+	if errs := validation.IsDNS1123Subdomain(strings.ToLower(source.CSI.Driver)); len(errs) > 0 {
+		return fmt.Errorf("invalid csi driver %q: %s", source.CSI.Driver, strings.Join(errs, ", "))
+	}
+	if source.CSI.VolumeHandle == "" {
+		return fmt.Errorf("csi volume handle must not be empty")
+	}
+	return nil
+}
+
+func (csiBackend) VolumeSource(source *Source, namespace string) (coreV1.PersistentVolumeSource, *coreV1.VolumeNodeAffinity) {
+	return coreV1.PersistentVolumeSource{
+		CSI: &coreV1.CSIPersistentVolumeSource{
+			Driver:           source.CSI.Driver,
+			VolumeHandle:     source.CSI.VolumeHandle,
+			FSType:           source.CSI.FSType,
+			VolumeAttributes: source.CSI.VolumeAttributes,
+		},
+	}, nil
+}
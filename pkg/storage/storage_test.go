@@ -0,0 +1,48 @@
+package storage
+
+import "testing"
+
+func TestSourceValidate(t *testing.T) {
+	AllowedHostPaths = []string{"/data/shared"}
+	defer func() { AllowedHostPaths = nil }()
+	hostPath := func(p string) *Source {
+		return &Source{Type: HostPath, HostPath: &HostPathParameters{Path: p, Nodes: []string{"node-1"}}}
+	}
+	cephFS := func(secretNamespace string) *Source {
+		return &Source{Type: CephFS, CephFS: &CephFSParameters{
+			Monitors: []string{"10.0.0.1:6789"}, SecretName: "ceph", SecretNamespace: secretNamespace}}
+	}
+	tests := []struct {
+		name      string
+		source    *Source
+		namespace string
+		valid     bool
+	}{
+		{name: "allowed host path", source: hostPath("/data/shared"), namespace: "clustar-a", valid: true},
+		{name: "below an allowed host path", source: hostPath("/data/shared/imagenet"), namespace: "clustar-a", valid: true},
+		{name: "root", source: hostPath("/"), namespace: "clustar-a"},
+		{name: "docker socket", source: hostPath("/var/run/docker.sock"), namespace: "clustar-a"},
+		{name: "sibling with the same prefix", source: hostPath("/data/shared-other"), namespace: "clustar-a"},
+		{name: "escaping the allowed host path", source: hostPath("/data/shared/../../etc"), namespace: "clustar-a"},
+		{name: "ceph secret of the claim namespace", source: cephFS("clustar-a"), namespace: "clustar-a", valid: true},
+		{name: "ceph secret defaulting to the claim namespace", source: cephFS(""), namespace: "clustar-a", valid: true},
+		{name: "ceph secret of another namespace", source: cephFS("kube-system"), namespace: "clustar-a"},
+		{name: "ceph secret namespace of a dataset", source: cephFS("clustar-a"), namespace: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.source.Validate(test.namespace)
+			if (err == nil) != test.valid {
+				t.Errorf("Validate = %v, valid expected: %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestCephFSSecretNamespace(t *testing.T) {
+	source := &Source{Type: CephFS, CephFS: &CephFSParameters{Monitors: []string{"10.0.0.1:6789"}, SecretName: "ceph"}}
+	volumeSource, _ := source.VolumeSource("clustar-a")
+	if volumeSource.CephFS.SecretRef.Namespace != "clustar-a" {
+		t.Errorf("secret namespace = %q, want the namespace of the claim", volumeSource.CephFS.SecretRef.Namespace)
+	}
+}